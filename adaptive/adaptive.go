@@ -0,0 +1,140 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+// Package adaptive adjusts the limits of [psg] pools automatically, based on
+// the latency and errors of the tasks that run in them, in the style of
+// Netflix's [concurrency-limits] library.
+//
+// A [Controller] observes the tasks of a single pool and, as each one
+// finishes, asks an [Algorithm] for the pool's new limit, which it then
+// applies with [psg.Pool.SetLimit]. This package provides the [AIMD], [Vegas],
+// and [Gradient2] algorithms:
+//
+//	pool := psg.NewPool(10, psg.WithPoolObserver(
+//		adaptive.NewController(&adaptive.Gradient2{}, adaptive.WithMaxLimit(200)),
+//	))
+//
+// The limit given to [psg.NewPool] serves as the starting point. A negative
+// (unlimited) starting limit is treated as the controller's maximum.
+//
+// [concurrency-limits]: https://github.com/Netflix/concurrency-limits
+package adaptive
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/petenewcomb/psg-go"
+)
+
+// A Sample describes the outcome of a single task, as passed to
+// [Algorithm.Update].
+type Sample struct {
+	// Latency is how long the task ran, including any retries.
+	Latency time.Duration
+	// InFlight is the number of tasks that were running in the pool, including
+	// this one, when the task was launched.
+	InFlight int
+	// Err is the error returned by the task.
+	Err error
+}
+
+// An Algorithm computes a new limit for a pool. Algorithms are typically
+// stateful, and so each instance should be used by a single [Controller].
+type Algorithm interface {
+	// Update returns the new limit for a pool given its current limit and the
+	// outcome of a task that just finished. The [Controller] clamps the result
+	// to its minimum and maximum, and never calls Update concurrently.
+	Update(limit int, s Sample) int
+}
+
+// A Controller is a [psg.PoolObserver] that adjusts the limit of the pool it
+// observes using an [Algorithm]. Attach it to a pool with
+// [psg.WithPoolObserver]. A Controller must observe only a single pool.
+//
+// Tasks that never start, because their job was canceled first, are ignored.
+type Controller struct {
+	alg      Algorithm
+	minLimit int
+	maxLimit int
+	mu       sync.Mutex
+}
+
+var _ psg.PoolObserver = (*Controller)(nil)
+
+// An Option configures a [Controller]. See [NewController].
+type Option func(*Controller)
+
+// WithMinLimit returns an [Option] that sets the lowest limit the controller
+// will apply. Values less than one are treated as one, which is the default.
+func WithMinLimit(limit int) Option {
+	return func(c *Controller) {
+		c.minLimit = max(limit, 1)
+	}
+}
+
+// WithMaxLimit returns an [Option] that sets the highest limit the controller
+// will apply. The default is 1000.
+func WithMaxLimit(limit int) Option {
+	return func(c *Controller) {
+		c.maxLimit = limit
+	}
+}
+
+// NewController creates a [Controller] that uses the given algorithm.
+func NewController(alg Algorithm, opts ...Option) *Controller {
+	c := &Controller{
+		alg:      alg,
+		minLimit: 1,
+		maxLimit: 1000,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.maxLimit = max(c.maxLimit, c.minLimit)
+	return c
+}
+
+type inFlightKeyType struct{}
+
+var inFlightKey any = inFlightKeyType{}
+
+// ScatterBlocked implements [psg.PoolObserver].
+func (c *Controller) ScatterBlocked(ctx context.Context, ev psg.ScatterBlockedEvent) {}
+
+// TaskLaunched records the number of tasks in flight as the task launches.
+func (c *Controller) TaskLaunched(ctx context.Context, ev psg.TaskLaunchedEvent) context.Context {
+	return context.WithValue(ev.TaskContext, inFlightKey, ev.Pool.Stats().InFlight)
+}
+
+// TaskFinished updates the pool's limit.
+func (c *Controller) TaskFinished(ctx context.Context, ev psg.TaskFinishedEvent) {
+	if ev.Attempts == 0 {
+		return
+	}
+	inFlight, _ := ctx.Value(inFlightKey).(int)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	limit := ev.Pool.Limit()
+	if limit < 0 {
+		limit = c.maxLimit
+	}
+	limit = c.alg.Update(min(max(limit, c.minLimit), c.maxLimit), Sample{
+		Latency:  ev.Duration,
+		InFlight: inFlight,
+		Err:      ev.Err,
+	})
+	ev.Pool.SetLimit(min(max(limit, c.minLimit), c.maxLimit))
+}
+
+// GatherStarted implements [psg.PoolObserver].
+func (c *Controller) GatherStarted(ctx context.Context, ev psg.GatherStartedEvent) context.Context {
+	return ctx
+}
+
+// GatherFinished implements [psg.PoolObserver].
+func (c *Controller) GatherFinished(ctx context.Context, ev psg.GatherFinishedEvent) {}
+
+// ResultDiscarded implements [psg.PoolObserver].
+func (c *Controller) ResultDiscarded(ctx context.Context, ev psg.ResultDiscardedEvent) {}
@@ -0,0 +1,114 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package adaptive_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/petenewcomb/psg-go"
+	"github.com/petenewcomb/psg-go/adaptive"
+	"github.com/stretchr/testify/require"
+)
+
+var errFailed = errors.New("failed")
+
+func TestAIMD(t *testing.T) {
+	chk := require.New(t)
+	a := &adaptive.AIMD{Timeout: time.Second}
+	chk.Equal(11, a.Update(10, adaptive.Sample{Latency: time.Millisecond, InFlight: 5}))
+	chk.Equal(10, a.Update(10, adaptive.Sample{Latency: time.Millisecond, InFlight: 4}))
+	chk.Equal(9, a.Update(10, adaptive.Sample{Latency: time.Millisecond, InFlight: 10, Err: errFailed}))
+	chk.Equal(9, a.Update(10, adaptive.Sample{Latency: 2 * time.Second, InFlight: 10}))
+
+	a = &adaptive.AIMD{BackoffRatio: 0.5}
+	chk.Equal(5, a.Update(10, adaptive.Sample{Latency: time.Hour, InFlight: 10, Err: errFailed}))
+	chk.Equal(11, a.Update(10, adaptive.Sample{Latency: time.Hour, InFlight: 10}))
+}
+
+func TestVegas(t *testing.T) {
+	chk := require.New(t)
+	v := &adaptive.Vegas{}
+
+	// Without queueing, the limit grows quickly.
+	limit := 10
+	for range 5 {
+		next := v.Update(limit, adaptive.Sample{Latency: 10 * time.Millisecond, InFlight: limit})
+		chk.Greater(next, limit)
+		limit = next
+	}
+
+	// Once latency rises, it shrinks.
+	next := v.Update(limit, adaptive.Sample{Latency: 100 * time.Millisecond, InFlight: limit})
+	chk.Less(next, limit)
+	limit = next
+
+	// As it does on failure.
+	next = v.Update(limit, adaptive.Sample{Latency: 10 * time.Millisecond, InFlight: limit, Err: errFailed})
+	chk.Less(next, limit)
+	limit = next
+
+	// But it holds steady while the pool is underused.
+	chk.Equal(limit, v.Update(limit, adaptive.Sample{Latency: 100 * time.Millisecond, InFlight: 1}))
+}
+
+func TestGradient2(t *testing.T) {
+	chk := require.New(t)
+	g := &adaptive.Gradient2{}
+
+	// With steady latency, the limit grows.
+	limit := 10
+	for range 20 {
+		limit = g.Update(limit, adaptive.Sample{Latency: 10 * time.Millisecond, InFlight: limit})
+	}
+	chk.Greater(limit, 10)
+
+	// A sharp rise in latency shrinks it.
+	next := limit
+	for range 5 {
+		next = g.Update(next, adaptive.Sample{Latency: 100 * time.Millisecond, InFlight: next})
+	}
+	chk.Less(next, limit)
+}
+
+func TestControllerAdjustsPoolLimit(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	controller := adaptive.NewController(&adaptive.AIMD{},
+		adaptive.WithMinLimit(2),
+		adaptive.WithMaxLimit(4),
+	)
+	pool := psg.NewPool(2, psg.WithPoolObserver(controller))
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	scatter := func(err error) {
+		chk.NoError(psg.Scatter(ctx, pool,
+			func(ctx context.Context) (int, error) {
+				time.Sleep(time.Millisecond)
+				return 0, err
+			},
+			func(ctx context.Context, result int, err error) error {
+				return nil
+			},
+		))
+	}
+
+	// Successes in a saturated pool raise the limit, but not beyond the
+	// maximum.
+	for range 20 {
+		scatter(nil)
+	}
+	chk.Equal(4, pool.Limit())
+
+	// Failures lower it, but not below the minimum.
+	for range 10 {
+		scatter(errFailed)
+	}
+	job.Close()
+	chk.NoError(job.GatherAll(ctx))
+	chk.Equal(2, pool.Limit())
+}
@@ -0,0 +1,181 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package adaptive
+
+import (
+	"math"
+	"time"
+)
+
+// AIMD is an [Algorithm] that increases the limit additively while tasks
+// succeed and decreases it multiplicatively when they fail or are too slow,
+// like TCP congestion control.
+//
+// The limit increases by one after a successful task, but only if the pool was
+// at least half utilized when the task launched, so that the limit does not
+// grow unboundedly while the pool is underused.
+type AIMD struct {
+	// BackoffRatio is the factor by which the limit is multiplied after a
+	// failure. Values outside of (0, 1) are treated as 0.9.
+	BackoffRatio float64
+
+	// Timeout is the latency beyond which a task is treated as a failure. Zero
+	// means that only errors count as failures.
+	Timeout time.Duration
+}
+
+// Update implements [Algorithm].
+func (a *AIMD) Update(limit int, s Sample) int {
+	if s.Err != nil || (a.Timeout > 0 && s.Latency > a.Timeout) {
+		ratio := a.BackoffRatio
+		if ratio <= 0 || ratio >= 1 {
+			ratio = 0.9
+		}
+		return int(float64(limit) * ratio)
+	}
+	if s.InFlight*2 >= limit {
+		return limit + 1
+	}
+	return limit
+}
+
+// Vegas is an [Algorithm] based on TCP Vegas. It estimates the number of tasks
+// queued behind a bottleneck by comparing each task's latency to the lowest
+// latency observed, and adjusts the limit to keep that estimate between alpha
+// and beta, which scale with the logarithm of the limit. Failures decrease the
+// limit.
+type Vegas struct {
+	// Alpha and Beta are the factors, multiplied by the base-10 logarithm of
+	// the limit, giving the estimated queue sizes below which the limit
+	// increases and above which it decreases. Non-positive values are
+	// treated as 3 and 6 respectively.
+	Alpha, Beta float64
+
+	// Smoothing is the weight, between 0 and 1, given to each new limit
+	// relative to the old one. Values outside of (0, 1] are treated as 1,
+	// meaning no smoothing.
+	Smoothing float64
+
+	// The lowest latency observed, taken as the latency without queueing.
+	minLatency time.Duration
+	// The current limit, which may be fractional.
+	estimate float64
+}
+
+// Update implements [Algorithm].
+func (v *Vegas) Update(limit int, s Sample) int {
+	if v.minLatency == 0 || s.Latency < v.minLatency {
+		v.minLatency = max(s.Latency, 1)
+	}
+	if v.estimate == 0 || int(v.estimate) != limit {
+		// Adopt the limit if it was changed by someone else.
+		v.estimate = float64(limit)
+	}
+	alpha, beta := v.Alpha, v.Beta
+	if alpha <= 0 {
+		alpha = 3
+	}
+	if beta <= 0 {
+		beta = 6
+	}
+
+	current := v.estimate
+	step := max(1, math.Log10(current))
+	next := current
+	switch {
+	case s.Err != nil:
+		next = current - step
+	case s.InFlight*2 < limit:
+		// The pool is underused, so latency says nothing about the limit.
+	default:
+		queue := current * (1 - float64(v.minLatency)/float64(max(s.Latency, 1)))
+		switch {
+		case queue <= step:
+			next = current + beta*step
+		case queue < alpha*step:
+			next = current + step
+		case queue > beta*step:
+			next = current - step
+		}
+	}
+	v.estimate = smooth(current, next, v.Smoothing, 1)
+	return int(v.estimate)
+}
+
+// Gradient2 is an [Algorithm] based on the Gradient2 algorithm of Netflix's
+// concurrency-limits library. It compares a short-term measure of latency (the
+// latest sample) to a long-term exponential moving average, decreasing the
+// limit in proportion as latency rises and otherwise growing it by a queue
+// allowance of the square root of the limit. Failures count only through their
+// latency.
+type Gradient2 struct {
+	// Tolerance is the ratio by which short-term latency may exceed the
+	// long-term average before the limit decreases. Values less than one are
+	// treated as 1.5.
+	Tolerance float64
+
+	// LongWindow is the number of samples over which the long-term average is
+	// taken. Non-positive values are treated as 600.
+	LongWindow int
+
+	// Smoothing is the weight, between 0 and 1, given to each new limit
+	// relative to the old one. Values outside of (0, 1] are treated as 0.2.
+	Smoothing float64
+
+	// The long-term average latency, in nanoseconds.
+	longLatency float64
+	// The number of samples averaged so far, up to LongWindow.
+	samples int
+	// The current limit, which may be fractional.
+	estimate float64
+}
+
+// Update implements [Algorithm].
+func (g *Gradient2) Update(limit int, s Sample) int {
+	tolerance := g.Tolerance
+	if tolerance < 1 {
+		tolerance = 1.5
+	}
+	window := g.LongWindow
+	if window <= 0 {
+		window = 600
+	}
+	if g.estimate == 0 || int(g.estimate) != limit {
+		g.estimate = float64(limit)
+	}
+
+	// Warm up the long-term average with a simple mean before switching to an
+	// exponential one.
+	short := float64(max(s.Latency, 1))
+	if g.samples < window {
+		g.samples++
+		g.longLatency += (short - g.longLatency) / float64(g.samples)
+	} else {
+		g.longLatency += (short - g.longLatency) / float64(window)
+	}
+	// Recover quickly from a sustained drop in latency, which would otherwise
+	// leave the long-term average, and therefore the limit, too high.
+	if g.longLatency/short > 2 {
+		g.longLatency *= 0.95
+	}
+
+	current := g.estimate
+	if s.InFlight*2 < limit {
+		// The pool is underused, so latency says nothing about the limit.
+		return limit
+	}
+	gradient := max(0.5, min(1, tolerance*g.longLatency/short))
+	next := current*gradient + math.Sqrt(current)
+	g.estimate = smooth(current, next, g.Smoothing, 0.2)
+	return int(g.estimate)
+}
+
+// Returns the weighted average of the current and next values, using the
+// given smoothing weight or the default if the weight is out of range.
+func smooth(current, next, weight, defaultWeight float64) float64 {
+	if weight <= 0 || weight > 1 {
+		weight = defaultWeight
+	}
+	return max(1, current*(1-weight)+next*weight)
+}
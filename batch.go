@@ -0,0 +1,61 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg
+
+import (
+	"context"
+	"iter"
+	"slices"
+)
+
+// ScatterAll launches a task into the given pool for each of the given inputs,
+// in order, as if by calling [Scatter] for each. The task and gather functions
+// for each input are created by calling newTask and newGather with that input,
+// and the given options apply to every task.
+//
+// Like Scatter, ScatterAll applies backpressure to stay within the pool's
+// limits by gathering other tasks in the job while it waits, so it returns
+// only once every task has been launched. Tasks are not gathered by
+// ScatterAll once launched, however; gather them as usual with the gathering
+// methods of [Job].
+//
+// ScatterAll stops at the first error that Scatter would return, without
+// launching a task for that input or any after it, and returns the error.
+// Tasks launched for earlier inputs remain part of the job.
+func ScatterAll[I, T any](
+	ctx context.Context,
+	pool *Pool,
+	inputs []I,
+	newTask func(I) TaskFunc[T],
+	newGather func(I) GatherFunc[T],
+	opts ...ScatterOption,
+) error {
+	return ScatterSeq(ctx, pool, slices.Values(inputs), newTask, newGather, opts...)
+}
+
+// ScatterSeq is like [ScatterAll], but takes its inputs from the given
+// sequence. The sequence is consumed only as fast as tasks can be launched,
+// and no further inputs are requested from it once an error occurs. This
+// allows inputs to be produced lazily, for instance while walking a directory
+// tree (see the pipeline example).
+func ScatterSeq[I, T any](
+	ctx context.Context,
+	pool *Pool,
+	inputs iter.Seq[I],
+	newTask func(I) TaskFunc[T],
+	newGather func(I) GatherFunc[T],
+	opts ...ScatterOption,
+) error {
+	// Apply the options and check that the pool can be launched into just
+	// once for the whole batch.
+	cfg := newScatterConfig(opts)
+	j := pool.launchJob(ctx)
+	for input := range inputs {
+		task := bindTask(pool, cfg, newTask(input), newGather(input))
+		if _, err := pool.launchIn(ctx, j, cfg, task, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
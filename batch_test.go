@@ -0,0 +1,232 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/petenewcomb/psg-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScatterAll(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	const limit = 3
+	pool := psg.NewPool(limit)
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	inputs := make([]int, 50)
+	for i := range inputs {
+		inputs[i] = i
+	}
+
+	var running, maxRunning atomic.Int64
+	newTask := func(input int) psg.TaskFunc[int] {
+		return func(ctx context.Context) (int, error) {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			return input * input, nil
+		}
+	}
+	results := make(map[int]int)
+	newGather := func(input int) psg.GatherFunc[int] {
+		return func(ctx context.Context, result int, err error) error {
+			chk.NoError(err)
+			results[input] = result
+			return nil
+		}
+	}
+
+	chk.NoError(psg.ScatterAll(ctx, pool, inputs, newTask, newGather))
+	chk.NoError(job.CloseAndGatherAll(ctx))
+
+	chk.Len(results, len(inputs))
+	for _, input := range inputs {
+		chk.Equal(input*input, results[input])
+	}
+	chk.LessOrEqual(maxRunning.Load(), int64(limit))
+}
+
+func TestScatterAllEmpty(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(1)
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	chk.NoError(psg.ScatterAll(ctx, pool, nil,
+		func(input int) psg.TaskFunc[int] {
+			panic("no task should be created")
+		},
+		func(input int) psg.GatherFunc[int] {
+			panic("no gather should be created")
+		},
+	))
+	chk.NoError(job.CloseAndGatherAll(ctx))
+}
+
+func TestScatterAllOptions(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(2)
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	// Each task weighs the whole limit, so they must run one at a time.
+	var running atomic.Int64
+	newTask := func(input int) psg.TaskFunc[int64] {
+		return func(ctx context.Context) (int64, error) {
+			defer running.Add(-1)
+			return running.Add(1), nil
+		}
+	}
+	newGather := func(input int) psg.GatherFunc[int64] {
+		return func(ctx context.Context, result int64, err error) error {
+			chk.NoError(err)
+			chk.Equal(int64(1), result)
+			return nil
+		}
+	}
+
+	chk.NoError(psg.ScatterAll(ctx, pool, []int{1, 2, 3, 4, 5}, newTask, newGather, psg.WithWeight(2)))
+	chk.NoError(job.CloseAndGatherAll(ctx))
+	chk.Equal(uint64(5), pool.Stats().Launched)
+}
+
+func TestScatterSeqStopsOnError(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(1)
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	gatherErr := errors.New("gather failed")
+	consumed := 0
+	inputs := func(yield func(int) bool) {
+		for i := 0; ; i++ {
+			consumed++
+			if !yield(i) {
+				return
+			}
+		}
+	}
+	newTask := func(input int) psg.TaskFunc[int] {
+		return func(ctx context.Context) (int, error) {
+			return input, nil
+		}
+	}
+	newGather := func(input int) psg.GatherFunc[int] {
+		return func(ctx context.Context, result int, err error) error {
+			if result == 2 {
+				return gatherErr
+			}
+			return nil
+		}
+	}
+
+	// With a limit of one, the gather of the task for input 2 runs while
+	// launching the task for input 3, which is therefore not launched.
+	err := psg.ScatterSeq(ctx, pool, inputs, newTask, newGather)
+	chk.ErrorIs(err, gatherErr)
+	chk.Equal(4, consumed)
+	chk.Equal(uint64(3), pool.Stats().Launched)
+}
+
+func TestScatterSeqCanceled(t *testing.T) {
+	chk := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := psg.NewPool(1)
+	job := psg.NewJob(context.Background(), pool)
+	defer job.CancelAndWait()
+
+	inputs := func(yield func(int) bool) {
+		for i := 0; ; i++ {
+			if i == 2 {
+				cancel()
+			}
+			if !yield(i) {
+				return
+			}
+		}
+	}
+	newTask := func(input int) psg.TaskFunc[int] {
+		return func(ctx context.Context) (int, error) {
+			return input, nil
+		}
+	}
+	newGather := func(input int) psg.GatherFunc[int] {
+		return func(ctx context.Context, result int, err error) error {
+			return nil
+		}
+	}
+
+	err := psg.ScatterSeq(ctx, pool, inputs, newTask, newGather)
+	chk.ErrorIs(err, context.Canceled)
+	chk.Equal(uint64(2), pool.Stats().Launched)
+}
+
+func TestScatterSeqPoolRemoved(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(-1)
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	inputs := func(yield func(int) bool) {
+		for i := 0; ; i++ {
+			if i == 2 {
+				job.RemovePool(pool)
+			}
+			if !yield(i) {
+				return
+			}
+		}
+	}
+	newTask := func(input int) psg.TaskFunc[int] {
+		return func(ctx context.Context) (int, error) {
+			return input, nil
+		}
+	}
+	newGather := func(input int) psg.GatherFunc[int] {
+		return func(ctx context.Context, result int, err error) error {
+			return nil
+		}
+	}
+
+	err := psg.ScatterSeq(ctx, pool, inputs, newTask, newGather)
+	chk.ErrorIs(err, psg.ErrPoolRemoved)
+	chk.Equal(uint64(2), pool.Stats().Launched)
+}
+
+func TestScatterAllPoolNotBoundPanic(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(1)
+
+	chk.PanicsWithValue("pool not bound to a job", func() {
+		_ = psg.ScatterAll(ctx, pool, []int{1},
+			func(input int) psg.TaskFunc[int] {
+				return func(ctx context.Context) (int, error) {
+					return input, nil
+				}
+			},
+			func(input int) psg.GatherFunc[int] {
+				return func(ctx context.Context, result int, err error) error {
+					return nil
+				}
+			},
+		)
+	})
+}
@@ -0,0 +1,146 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg
+
+import (
+	"context"
+	"iter"
+	"sync"
+
+	"github.com/gammazero/deque"
+)
+
+// A Collector scatters tasks that produce results of type T and makes their
+// results available as an iterator, as an alternative to supplying a
+// [GatherFunc] for each task. For instance:
+//
+//	c := psg.NewCollector[string](job)
+//	for _, url := range urls {
+//		if err := c.Scatter(ctx, pool, newFetchTask(url)); err != nil {
+//			return err
+//		}
+//	}
+//	job.Close()
+//	for page, err := range c.All(ctx) {
+//		...
+//	}
+//
+// Results are collected by a GatherFunc that simply queues them within the
+// Collector, so gathering for any reason (including the backpressure applied
+// by [Scatter]) moves them into the queue, from which [Collector.All] yields
+// them. A Collector may be used from multiple goroutines concurrently.
+type Collector[T any] struct {
+	job     *Job
+	mu      sync.Mutex
+	results deque.Deque[collectedResult[T]]
+}
+
+type collectedResult[T any] struct {
+	value T
+	err   error
+}
+
+// NewCollector creates a [Collector] for tasks in the given job.
+func NewCollector[T any](job *Job) *Collector[T] {
+	return &Collector[T]{
+		job: job,
+	}
+}
+
+// Scatter launches a task like [Scatter], arranging for its result to be
+// yielded by [Collector.All]. The pool must be bound to the Collector's job,
+// or Scatter panics.
+func (c *Collector[T]) Scatter(
+	ctx context.Context,
+	pool *Pool,
+	taskFunc TaskFunc[T],
+	opts ...ScatterOption,
+) error {
+	c.checkPool(pool)
+	return Scatter(ctx, pool, taskFunc, c.gather, opts...)
+}
+
+// TryScatter launches a task like [TryScatter], arranging for its result to be
+// yielded by [Collector.All]. The pool must be bound to the Collector's job,
+// or TryScatter panics.
+func (c *Collector[T]) TryScatter(
+	ctx context.Context,
+	pool *Pool,
+	taskFunc TaskFunc[T],
+	opts ...ScatterOption,
+) (bool, error) {
+	c.checkPool(pool)
+	return TryScatter(ctx, pool, taskFunc, c.gather, opts...)
+}
+
+// Panics if the given pool is not bound to the Collector's job, since its
+// tasks would be gathered by another job or none.
+func (c *Collector[T]) checkPool(pool *Pool) {
+	if pool.job.Load() != c.job {
+		panic("pool not bound to the collector's job")
+	}
+}
+
+func (c *Collector[T]) gather(ctx context.Context, value T, err error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results.PushBack(collectedResult[T]{value, err})
+	return nil
+}
+
+func (c *Collector[T]) pop() (collectedResult[T], bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.results.Len() == 0 {
+		return collectedResult[T]{}, false
+	}
+	return c.results.PopFront(), true
+}
+
+// All returns an iterator over the results of the Collector's tasks, yielding
+// each task's result and error as the task is gathered. The iterator gathers
+// via [Job.GatherOne], so it blocks while waiting for tasks to complete and
+// also calls the gather functions of any other tasks in the job as they come
+// up. Like [Job.GatherAll], it ends only once the job has been closed (see
+// [Job.Close]) and all of its tasks have been gathered.
+//
+// If gathering fails, for instance because the context or the job was
+// canceled or because another task's gather function returned an error, the
+// iterator yields the error along with the zero value of T. It then ends if
+// the failure was due to cancellation, or otherwise continues.
+//
+// Exiting the loop early leaves any remaining tasks running and their results
+// uncollected; a later call to All will pick up where the previous one left
+// off. To abandon the remaining tasks instead, cancel the job.
+func (c *Collector[T]) All(ctx context.Context) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		// Yields everything collected so far, including results gathered
+		// outside of this iterator. Returns false if the loop was exited.
+		drain := func() bool {
+			for {
+				r, ok := c.pop()
+				if !ok {
+					return true
+				}
+				if !yield(r.value, r.err) {
+					return false
+				}
+			}
+		}
+		for drain() {
+			ok, err := c.job.GatherOne(ctx)
+			if err != nil {
+				var zero T
+				if !yield(zero, err) || !ok {
+					return
+				}
+			} else if !ok {
+				// The job is done, but results may have been collected
+				// concurrently since the last drain.
+				drain()
+				return
+			}
+		}
+	}
+}
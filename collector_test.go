@@ -0,0 +1,120 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/petenewcomb/psg-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(2)
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	c := psg.NewCollector[int](job)
+	errOdd := errors.New("odd")
+	const taskCount = 10
+	for i := range taskCount {
+		chk.NoError(c.Scatter(ctx, pool, func(ctx context.Context) (int, error) {
+			if i%2 != 0 {
+				return i, errOdd
+			}
+			return i, nil
+		}))
+	}
+	job.Close()
+
+	var values []int
+	for v, err := range c.All(ctx) {
+		if v%2 != 0 {
+			chk.ErrorIs(err, errOdd)
+		} else {
+			chk.NoError(err)
+		}
+		values = append(values, v)
+	}
+	slices.Sort(values)
+	chk.Equal([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, values)
+}
+
+func TestCollectorEarlyExit(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(1)
+	job := psg.NewJob(ctx, pool, psg.WithOrderedGather(0))
+	defer job.CancelAndWait()
+
+	c := psg.NewCollector[int](job)
+	for i := range 3 {
+		chk.NoError(c.Scatter(ctx, pool, func(ctx context.Context) (int, error) {
+			return i, nil
+		}))
+	}
+	job.Close()
+
+	// Exit after the first result, then pick up the rest.
+	for v, err := range c.All(ctx) {
+		chk.NoError(err)
+		chk.Equal(0, v)
+		break
+	}
+	var values []int
+	for v, err := range c.All(ctx) {
+		chk.NoError(err)
+		values = append(values, v)
+	}
+	chk.Equal([]int{1, 2}, values)
+}
+
+func TestCollectorCanceled(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(1)
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	c := psg.NewCollector[int](job)
+	chk.NoError(c.Scatter(ctx, pool, func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}))
+	job.Cancel()
+
+	var errs []error
+	for _, err := range c.All(ctx) {
+		errs = append(errs, err)
+	}
+	chk.Len(errs, 1)
+	chk.ErrorIs(errs[0], context.Canceled)
+}
+
+func TestCollectorOtherJobPanic(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(1)
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+	otherJob := psg.NewJob(ctx)
+	defer otherJob.CancelAndWait()
+
+	c := psg.NewCollector[int](otherJob)
+	task := func(ctx context.Context) (int, error) {
+		return 0, nil
+	}
+	chk.PanicsWithValue("pool not bound to the collector's job", func() {
+		_ = c.Scatter(ctx, pool, task)
+	})
+	chk.PanicsWithValue("pool not bound to the collector's job", func() {
+		_, _ = c.TryScatter(ctx, pool, task)
+	})
+	chk.Zero(pool.Stats().Launched)
+}
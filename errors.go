@@ -0,0 +1,117 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// AggregateError is returned by [Job.GatherAll], [Job.TryGatherAll], and
+// [Job.CloseAndGatherAll] for a job created with [WithErrorAggregation]. It
+// lists the errors encountered while gathering in the order they occurred and
+// is compatible with [errors.Is] and [errors.As], which will match any of them.
+type AggregateError struct {
+	// Errors holds the collected errors, up to the limit given to
+	// WithErrorAggregation.
+	Errors []error
+	// Omitted is the number of additional errors that occurred after the limit
+	// was reached and were therefore discarded.
+	Omitted int
+}
+
+func (e *AggregateError) Error() string {
+	var b strings.Builder
+	for i, err := range e.Errors {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(err.Error())
+	}
+	if e.Omitted > 0 {
+		if len(e.Errors) > 0 {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(&b, "(%d more errors omitted)", e.Omitted)
+	}
+	return b.String()
+}
+
+// Unwrap returns the collected errors.
+func (e *AggregateError) Unwrap() []error {
+	return e.Errors
+}
+
+// WithErrorAggregation returns a [JobOption] that makes [Job.GatherAll],
+// [Job.TryGatherAll], and [Job.CloseAndGatherAll] continue gathering after a
+// [GatherFunc] returns a non-nil error, instead of returning immediately. When
+// they do return, the errors encountered are combined into an
+// [*AggregateError]. Errors returned by gather functions called while
+// [Scatter] is applying backpressure are also collected, rather than causing
+// Scatter to fail, and are reported by the next call to one of the above
+// methods.
+//
+// At most limit errors are retained, after which further errors are only
+// counted; a non-positive limit means that all errors are retained.
+//
+// [Job.GatherOne] and [Job.TryGatherOne] are unaffected and continue to return
+// the error of the gather function they call.
+func WithErrorAggregation(limit int) JobOption {
+	return jobOptionFunc(func(j *Job) {
+		j.errs = &errorCollector{
+			limit: limit,
+		}
+	})
+}
+
+// Collects errors on behalf of a Job in error aggregation mode. A nil
+// *errorCollector represents a job that is not in aggregation mode.
+type errorCollector struct {
+	mu      sync.Mutex
+	limit   int
+	errs    []error
+	omitted int
+}
+
+// Records the given gather error. Returns false if the job is not in
+// aggregation mode, meaning that the error should be returned to the caller
+// instead.
+func (c *errorCollector) collect(err error) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.limit <= 0 || len(c.errs) < c.limit {
+		c.errs = append(c.errs, err)
+	} else {
+		c.omitted++
+	}
+	return true
+}
+
+// Returns and resets the collected errors, along with the given final error
+// if non-nil. If the job is not in aggregation mode, returns just the final
+// error.
+func (c *errorCollector) take(final error) error {
+	if c == nil {
+		return final
+	}
+	if final != nil {
+		c.collect(final)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.errs) == 0 && c.omitted == 0 {
+		return nil
+	}
+	err := &AggregateError{
+		Errors:  c.errs,
+		Omitted: c.omitted,
+	}
+	c.errs = nil
+	c.omitted = 0
+	return err
+}
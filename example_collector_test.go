@@ -0,0 +1,40 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/petenewcomb/psg-go"
+)
+
+// Demonstrates consuming task results with a range-over-func loop instead of
+// gather functions. Ordered gathering makes the output deterministic.
+func ExampleCollector() {
+	ctx := context.Background()
+	pool := psg.NewPool(2)
+	job := psg.NewJob(ctx, pool, psg.WithOrderedGather(0))
+	defer job.CancelAndWait()
+
+	c := psg.NewCollector[string](job)
+	for _, s := range []string{"Hello", "world!"} {
+		if err := c.Scatter(ctx, pool, newTask(s)); err != nil {
+			fmt.Println("scatter error:", err)
+		}
+	}
+	job.Close()
+
+	for result, err := range c.All(ctx) {
+		if err != nil {
+			fmt.Println("error:", err)
+			continue
+		}
+		fmt.Println(result)
+	}
+
+	// Output:
+	// Hello
+	// world!
+}
@@ -84,21 +84,24 @@ func MD5All(ctx context.Context, root string) (map[string][md5.Size]byte, error)
 	job := psg.NewJob(ctx, readerPool, digesterPool)
 	defer job.CancelAndWait()
 
-	// Walk the tree and launch a reading task for each regular file.
-	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.Mode().IsRegular() {
+	// Walk the tree and launch a reading task for each regular file. The walk
+	// stops early if a task cannot be launched.
+	var walkErr error
+	paths := func(yield func(string) bool) {
+		walkErr = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.Mode().IsRegular() && !yield(path) {
+				return filepath.SkipAll
+			}
 			return nil
-		}
-		return psg.Scatter(
-			ctx,
-			readerPool,
-			newReadingTask(path),
-			newReadGather(path),
-		)
-	})
+		})
+	}
+	err := psg.ScatterSeq(ctx, readerPool, paths, newReadingTask, newReadGather)
+	if err == nil {
+		err = walkErr
+	}
 	if err != nil {
 		return nil, err
 	}
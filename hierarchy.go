@@ -0,0 +1,203 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg
+
+import "slices"
+
+// WithParent returns a [PoolOption] that makes the pool a child of the given
+// parent pool. A task launched into a child pool occupies its weight in the
+// limits of both the child and the parent (and the parent's parent, if any),
+// and is launched only once there is room in all of them. This allows, for
+// instance, a per-host limit on network requests to be combined with a global
+// one:
+//
+//	network := psg.NewPool(200)
+//	hostA := psg.NewPool(8, psg.WithParent(network))
+//	hostB := psg.NewPool(8, psg.WithParent(network))
+//	job := psg.NewJob(ctx, network, hostA, hostB)
+//
+// The parent must be bound to the same [Job] as the child, and tasks may be
+// launched into it directly as well. A launch defers to blocked launches of
+// higher priority (see [WithPriority]) only where they are held up by a pool
+// whose capacity it also needs, so launches into a child never wait on those
+// held up by a sibling's limit. Rate limits (see [Pool.SetRateLimit]) apply
+// only to tasks launched into the pool that has them, not to those of its
+// descendants.
+//
+// Children may be created at any time, even while tasks are running in the
+// parent, as they are by a [KeyedPool] given WithParent via
+// [WithSubPoolOptions].
+func WithParent(parent *Pool) PoolOption {
+	if parent == nil {
+		panic("parent pool must be non-nil")
+	}
+	return func(p *Pool) {
+		p.parent = parent
+		parent.addChild(p)
+	}
+}
+
+// Adds the given pool to those woken when the pool's capacity changes.
+func (p *Pool) addChild(c *Pool) {
+	for {
+		old := p.children.Load()
+		var children []*Pool
+		if old != nil {
+			children = slices.Clip(*old)
+		}
+		children = append(children, c)
+		if p.children.CompareAndSwap(old, &children) {
+			break
+		}
+	}
+	p.hasChildren.Store(true)
+}
+
+// Removes the given pool from the pool's children once it will no longer be
+// used, as when a KeyedPool evicts it.
+func (p *Pool) removeChild(c *Pool) {
+	for {
+		old := p.children.Load()
+		if old == nil {
+			return
+		}
+		children := slices.DeleteFunc(slices.Clone(*old), func(q *Pool) bool {
+			return q == c
+		})
+		if p.children.CompareAndSwap(old, &children) {
+			return
+		}
+	}
+}
+
+// Returns the pool at the top of the pool's hierarchy.
+func (p *Pool) root() *Pool {
+	for p.parent != nil {
+		p = p.parent
+	}
+	return p
+}
+
+// Adds the given weight to the in-flight counts of the pool and all of its
+// ancestors, and of any shared pools of which they are members (see
+// shared.go), if it fits within all of their limits. Otherwise returns the
+// pool whose limit, or whose shared pool's, refused it.
+func (p *Pool) addInFlightIfWithinLimits(weight int) (bool, *Pool) {
+	if p.parent == nil && !p.hasChildren.Load() && p.shared == nil {
+		return p.addInFlightIfWithinLimit(weight), p
+	}
+	// Serialize additions within the hierarchy so that one never fails due to
+	// the tentative addition of another that is about to be backed out.
+	// Subtractions need no such protection.
+	root := p.root()
+	root.hierarchyMu.Lock()
+	defer root.hierarchyMu.Unlock()
+	for q := p; q != nil; q = q.parent {
+		if !q.addInFlightIfWithinLimit(weight) {
+			if q != p {
+				p.backOut(q, weight)
+			}
+			return false, q
+		}
+	}
+	// Only then acquire from shared pools, whose additions are not serialized
+	// with those of other hierarchies and so should be backed out as rarely
+	// as possible. Backing out wakes any launches refused in the meantime.
+	for q := p; q != nil; q = q.parent {
+		if q.shared != nil && !q.shared.acquire(q, weight) {
+			for r := p; r != q; r = r.parent {
+				if r.shared != nil {
+					r.shared.release(r, weight)
+				}
+			}
+			p.backOut(nil, weight)
+			return false, q
+		}
+	}
+	return true, nil
+}
+
+// Backs out the tentative addition of the given weight to the in-flight counts
+// of the pool and its ancestors up to but not including the given one. An
+// addition to an ancestor that was not serialized with this one, because it
+// was made before the ancestor had children, may have failed due to the
+// tentative addition, so any blocked launches are woken.
+func (p *Pool) backOut(until *Pool, weight int) {
+	for r := p; r != until; r = r.parent {
+		r.subtractWeight(weight)
+	}
+	p.wakeWaiters()
+}
+
+func (p *Pool) subtractWeight(weight int) {
+	p.statsMu.Lock()
+	p.inFlight.Subtract(weight)
+	p.statsMu.Unlock()
+}
+
+func (p *Pool) subtractOwnInFlight(weight int) {
+	p.subtractWeight(weight)
+	if p.shared != nil {
+		p.shared.release(p, weight)
+	}
+}
+
+// Returns the pool, among the pool and its ancestors, that is holding up a
+// blocked launch with a priority greater than the given one, or nil if there
+// is none. A launch that needs capacity in that pool must defer to the blocked
+// one, but a launch into a sibling pool need not, since it does not compete for
+// the capacity that the blocked launch is waiting for.
+func (p *Pool) waitingAbove(priority int) *Pool {
+	for q := p; q != nil; q = q.parent {
+		if q.waiters.anyAbove(priority) {
+			return q
+		}
+	}
+	return nil
+}
+
+// Registers a blocked launch with the pool and all of its ancestors, see
+// Pool.waiting.
+func (p *Pool) addWaiter() {
+	for q := p; q != nil; q = q.parent {
+		q.statsMu.Lock()
+		q.waiting.Add(1)
+		if q == p {
+			q.blocked++
+		}
+		q.statsMu.Unlock()
+		if q.shared != nil {
+			q.shared.addWaiting(q, 1)
+		}
+	}
+}
+
+func (p *Pool) removeWaiter() {
+	for q := p; q != nil; q = q.parent {
+		q.statsMu.Lock()
+		q.waiting.Add(-1)
+		if q == p {
+			q.blocked--
+		}
+		q.statsMu.Unlock()
+		if q.shared != nil {
+			q.shared.addWaiting(q, -1)
+		}
+	}
+}
+
+// Records that a blocked launch with the given priority is being held up by
+// the pool, because the pool's limit or rate limit refused it or because it
+// deferred to another launch held up by the pool.
+func (p *Pool) addHeld(priority int) {
+	p.statsMu.Lock()
+	p.waiters.add(priority)
+	p.statsMu.Unlock()
+}
+
+func (p *Pool) removeHeld(priority int) {
+	p.statsMu.Lock()
+	p.waiters.remove(priority)
+	p.statsMu.Unlock()
+}
@@ -0,0 +1,123 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/petenewcomb/psg-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolParentLimits(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	parent := psg.NewPool(3)
+	childA := psg.NewPool(2, psg.WithParent(parent))
+	childB := psg.NewPool(2, psg.WithParent(parent))
+	job := psg.NewJob(ctx, parent, childA, childB)
+	defer job.CancelAndWait()
+
+	release := make(chan struct{})
+	task := func(ctx context.Context) (int, error) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return 0, nil
+	}
+	gather := func(ctx context.Context, result int, err error) error {
+		return err
+	}
+	tryScatter := func(pool *psg.Pool) bool {
+		ok, err := psg.TryScatter(ctx, pool, task, gather)
+		chk.NoError(err)
+		return ok
+	}
+
+	// The child's own limit applies.
+	chk.True(tryScatter(childA))
+	chk.True(tryScatter(childA))
+	chk.False(tryScatter(childA))
+
+	// The parent's limit is shared with its children.
+	chk.True(tryScatter(childB))
+	chk.False(tryScatter(childB))
+	chk.False(tryScatter(parent))
+	chk.Equal(3, parent.Stats().Weight)
+	chk.Equal(2, childA.Stats().Weight)
+	chk.Equal(1, childB.Stats().Weight)
+
+	// Finishing the tasks releases both the children and the parent.
+	close(release)
+	for range 3 {
+		ok, err := job.GatherOne(ctx)
+		chk.NoError(err)
+		chk.True(ok)
+	}
+	chk.Equal(0, parent.Stats().Weight)
+	chk.True(tryScatter(childB))
+	chk.True(tryScatter(childB))
+	chk.True(tryScatter(parent))
+	chk.False(tryScatter(childA))
+
+	chk.NoError(job.CloseAndGatherAll(ctx))
+}
+
+func TestPoolParentSiblingPriority(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	global := psg.NewPool(10)
+	busy := psg.NewPool(0, psg.WithParent(global))
+	idle := psg.NewPool(1, psg.WithParent(global))
+	job := psg.NewJob(ctx, global, busy, idle)
+	defer job.CancelAndWait()
+
+	task := func(ctx context.Context) (int, error) {
+		return 0, nil
+	}
+	gather := func(ctx context.Context, result int, err error) error {
+		return err
+	}
+
+	// Block a high-priority launch on the busy pool's limit.
+	blockedCtx, cancelBlocked := context.WithCancel(ctx)
+	defer cancelBlocked()
+	blockedErr := make(chan error, 1)
+	go func() {
+		blockedErr <- psg.Scatter(blockedCtx, busy, task, gather,
+			psg.WithPriority(5))
+	}()
+	chk.Eventually(func() bool {
+		return busy.Stats().Blocked == 1
+	}, time.Second, time.Millisecond)
+
+	// Launches into a sibling don't need the busy pool, so they don't defer
+	// to the blocked launch.
+	ok, err := psg.TryScatter(ctx, idle, task, gather)
+	chk.NoError(err)
+	chk.True(ok)
+	chk.NoError(psg.Scatter(ctx, idle, task, gather))
+
+	cancelBlocked()
+	chk.ErrorIs(<-blockedErr, context.Canceled)
+	chk.NoError(job.CloseAndGatherAll(ctx))
+}
+
+func TestPoolParentBoundToOtherJobPanic(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	parent := psg.NewPool(1)
+	child := psg.NewPool(1, psg.WithParent(parent))
+	job := psg.NewJob(ctx, child)
+	defer job.CancelAndWait()
+	chk.PanicsWithValue("parent pool not bound to the same job", func() {
+		_, _ = psg.TryScatter(ctx, child,
+			func(ctx context.Context) (int, error) { return 0, nil },
+			func(ctx context.Context, result int, err error) error { return nil },
+		)
+	})
+}
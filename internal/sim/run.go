@@ -16,13 +16,24 @@ import (
 )
 
 func Run(t require.TestingT, ctx context.Context, plan *Plan, debug bool) (map[*Plan]*Result, error) {
+	return run(t, ctx, plan, debug, nil)
+}
+
+// RunWithWorkers is like Run, but creates the pools with psg.WithWorkers so
+// that the simulated tasks run on reused goroutines.
+func RunWithWorkers(t require.TestingT, ctx context.Context, plan *Plan, debug bool) (map[*Plan]*Result, error) {
+	return run(t, ctx, plan, debug, []psg.PoolOption{psg.WithWorkers()})
+}
+
+func run(t require.TestingT, ctx context.Context, plan *Plan, debug bool, poolOpts []psg.PoolOption) (map[*Plan]*Result, error) {
 	pools := make([]*psg.Pool, len(plan.Config.ConcurrencyLimits))
 	for i, limit := range plan.Config.ConcurrencyLimits {
-		pools[i] = psg.NewPool(limit)
+		pools[i] = psg.NewPool(limit, poolOpts...)
 	}
 	c := &controller{
 		Plan:                 plan,
 		Pools:                pools,
+		PoolOptions:          poolOpts,
 		ConcurrencyByPool:    make([]atomic.Int64, len(pools)),
 		MaxConcurrencyByPool: make([]atomicMinMaxInt64, len(pools)),
 		ResultMap:            make(map[*Plan]*Result),
@@ -36,6 +47,7 @@ func Run(t require.TestingT, ctx context.Context, plan *Plan, debug bool) (map[*
 type controller struct {
 	Plan                 *Plan
 	Pools                []*psg.Pool
+	PoolOptions          []psg.PoolOption
 	ConcurrencyByPool    []atomic.Int64
 	MaxConcurrencyByPool []atomicMinMaxInt64
 	GatheredCount        atomic.Int64
@@ -51,7 +63,7 @@ func (c *controller) Run(t require.TestingT, ctx context.Context) (map[*Plan]*Re
 	c.StartTime = time.Now()
 	c.debugf("%v starting %v", time.Since(c.StartTime), c.Plan)
 
-	job := psg.NewJob(ctx, c.Pools...)
+	job := psg.NewJob(ctx, psg.WithPools(c.Pools...))
 	defer job.CancelAndWait()
 
 	for _, task := range c.Plan.RootTasks {
@@ -171,7 +183,7 @@ func (c *controller) newTaskFunc(task *Task, concurrency *atomic.Int64) psg.Task
 		for i, d := range task.SelfTimes {
 			if i > 0 {
 				subjobPlan := task.Subjobs[i-1]
-				resultMap, err := Run(lt, ctx, subjobPlan, c.Debug)
+				resultMap, err := run(lt, ctx, subjobPlan, c.Debug, c.PoolOptions)
 				chk.NoError(err)
 				c.addResultMap(lt, resultMap)
 			}
@@ -12,32 +12,48 @@ type InFlightCounter struct {
 }
 
 func (c *InFlightCounter) Increment() {
-	c.v.Add(1)
+	c.Add(1)
+}
+
+func (c *InFlightCounter) Add(n int) {
+	c.v.Add(int64(n))
 }
 
 func (c *InFlightCounter) IncrementIfUnder(limit int) bool {
-	// Tentatively increment the counter and check against limit. If over limit,
-	// remove the tentative increment and try again if we notice that another
-	// goroutine has made room between the increment and decrement.
-	for c.v.Add(1) > int64(limit) {
-		// Back out tentative increment and re-check.
-		if c.v.Add(-1) >= int64(limit) {
-			// Still at or over limit.
+	return c.AddIfWithin(1, limit)
+}
+
+func (c *InFlightCounter) AddIfWithin(n int, limit int) bool {
+	// Tentatively add to the counter and check against limit. If over limit,
+	// remove the tentative addition and try again if we notice that another
+	// goroutine has made room between the addition and subtraction.
+	for c.v.Add(int64(n)) > int64(limit) {
+		// Back out tentative addition and re-check.
+		if c.v.Add(-int64(n)) > int64(limit-n) {
+			// Still not enough room.
 			return false
 		}
 	}
-	// Incremented counter is within limit.
+	// Counter is within limit.
 	return true
 }
 
 func (c *InFlightCounter) Decrement() bool {
-	newValue := c.v.Add(-1)
+	return c.Subtract(1)
+}
+
+func (c *InFlightCounter) Subtract(n int) bool {
+	newValue := c.v.Add(-int64(n))
 	if newValue < 0 {
 		panic("there were no tasks in flight")
 	}
 	return newValue == 0
 }
 
+func (c *InFlightCounter) Value() int {
+	return int(c.v.Load())
+}
+
 func (c *InFlightCounter) GreaterThanZero() bool {
 	return c.v.Load() > 0
 }
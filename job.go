@@ -4,12 +4,17 @@
 package psg
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"maps"
+	"math"
+	"reflect"
 	"slices"
 	"sync"
 	"sync/atomic"
 
+	"github.com/addrummond/heap"
 	"github.com/petenewcomb/psg-go/internal/state"
 )
 
@@ -22,51 +27,186 @@ import (
 // A Job must be created with [NewJob], see that function for caveats and
 // important details.
 type Job struct {
-	ctx           context.Context
-	cancelFunc    context.CancelFunc
-	pools         []*Pool
-	inFlight      state.InFlightCounter
-	gatherChannel chan boundGatherFunc
-	wg            sync.WaitGroup
-	closed        atomic.Bool
-	done          chan struct{}
+	ctx        context.Context
+	cancelFunc context.CancelCauseFunc
+	failFast   bool
+	ordered    bool
+	errs       *errorCollector
+	keyedPools []boundKeyedPool
+	observers  []JobObserver
+	inFlight   state.InFlightCounter
+	wg         sync.WaitGroup
+	closed     atomic.Bool
+	done       chan struct{}
+
+	// Hands the gathers of completed tasks directly to goroutines waiting to
+	// gather, when they need not be ordered or buffered. Other gathers wait
+	// in the ready queue instead.
+	gathers chan readyGather
+	// Signals a goroutine waiting to gather that the ready queue may have a
+	// gather for it. Holds at most one signal, which a goroutine that takes a
+	// gather passes on if more remain.
+	readySignal chan struct{}
+	// The number of gathers in the ready queue.
+	readyLen atomic.Int64
+	// The context most recently marked by makeGatherContext.
+	gatherContext atomic.Pointer[markedContext]
+
+	// Protects the fields below.
+	mu sync.Mutex
+	// The pools bound to the job, see NewJob and AddPool.
+	pools []*Pool
+	// Gathers of completed tasks that are to be prioritized, ordered, or
+	// buffered, highest priority first.
+	ready heap.Heap[readyGather, heap.Max]
+	// Sequence number of the most recently posted gather, or in ordered mode,
+	// the number of tasks launched so far.
+	readySeq uint64
+	// In ordered mode, the maximum number of tasks that may be launched but
+	// not yet gathered, or zero if unbounded.
+	orderedWindow int
+	// In ordered mode, the sequence number of the next task to gather.
+	gatherSeq uint64
+	// The maximum number of gathers that may be posted without their tasks'
+	// goroutines waiting for them to be taken, and the number currently
+	// posted that way.
+	resultBuffer int
+	buffered     int
+	// In ordered mode, closed to notify blocked launches when room may have
+	// become available in the ordering window. Created on demand.
+	windowChanged chan struct{}
 }
 
 type boundGatherFunc = func(ctx context.Context) error
 
 // NewJob creates an independent scatter-gather execution environment with the
-// specified context and set of pools. The context passed to NewJob is used as
-// the root of the context that will be passed to all task functions. (See
+// specified context and options. The context passed to NewJob is used as the
+// root of the context that will be passed to all task functions. (See
 // [TaskFunc] and [Job.Cancel] for more detail.)
 //
-// Pools may not be shared across jobs. NewJob panics if it detects such
-// sharing, but such detection is not guaranteed to work if the same pool is
-// passed to NewJob calls in different goroutines.
+// Each [Pool] to be used with the job must be passed to NewJob, either directly
+// (a *Pool is itself a [JobOption]) or via [WithPools], as must each
+// [KeyedPool]. A slice of pools must be passed via WithPools, as in
+// NewJob(ctx, WithPools(pools...)). Other options, such as [WithFailFast],
+// configure the behavior of the job itself.
+//
+// Pools may not be shared across jobs, and NewJob panics if given a pool that
+// is already bound to a job. Pools may also be added to and removed from the
+// job after its creation with [Job.AddPool] and [Job.RemovePool]. Once the job
+// has finished, either because [Job.CancelAndWait] has returned or because
+// all of its tasks have been gathered after [Job.Close], its pools are unbound
+// from it and may be passed to another NewJob. A pool's configuration and
+// state, such as its limit and any observers, are retained across jobs.
+// To share a limit among concurrently running jobs, see [SharedPool].
 //
 // Each call to NewJob should typically be followed by a deferred call to
 // [Job.CancelAndWait] to ensure that an early exit from the calling function
 // does not leave any outstanding goroutines.
 func NewJob(
 	ctx context.Context,
-	pools ...*Pool,
+	opts ...JobOption,
 ) *Job {
-	ctx, cancelFunc := context.WithCancel(ctx)
+	ctx, cancelFunc := context.WithCancelCause(ctx)
 	j := &Job{
-		cancelFunc:    cancelFunc,
-		pools:         slices.Clone(pools),
-		gatherChannel: make(chan boundGatherFunc),
-		done:          make(chan struct{}),
+		cancelFunc:  cancelFunc,
+		done:        make(chan struct{}),
+		gathers:     make(chan readyGather),
+		readySignal: make(chan struct{}, 1),
 	}
 	j.ctx = j.makeTaskContext(ctx)
+	for _, opt := range opts {
+		opt.applyToJob(j)
+	}
 	for _, p := range j.pools {
-		if p.job != nil {
-			panic("pool was already registered")
-		}
-		p.job = j
+		p.bindObservers(j)
 	}
 	return j
 }
 
+// A JobOption configures a [Job] at creation time. See [NewJob].
+//
+// In addition to the options returned by functions like [WithFailFast], each
+// *[Pool] and *[KeyedPool] is a JobOption that binds the pool to the job.
+type JobOption interface {
+	applyToJob(j *Job)
+}
+
+type jobOptionFunc func(j *Job)
+
+func (f jobOptionFunc) applyToJob(j *Job) {
+	f(j)
+}
+
+// WithPools returns a [JobOption] that binds each of the given pools to the
+// job. It is useful when the pools are held in a slice.
+func WithPools(pools ...*Pool) JobOption {
+	return jobOptionFunc(func(j *Job) {
+		for _, p := range pools {
+			p.applyToJob(j)
+		}
+	})
+}
+
+// WithOrderedGather returns a [JobOption] that makes the job gather the results
+// of its tasks in the order in which the tasks were launched, rather than in the
+// order in which they complete. Results of tasks that complete early are held
+// until all earlier tasks have been gathered. Priorities set with
+// [WithPriority] still affect the order of launch, but not of gathering.
+//
+// To bound the number of results held in this way, at most window tasks may be
+// launched but not yet gathered at any one time. [Scatter] applies backpressure
+// to stay within this window just as it does to stay within the limit of a
+// [Pool], and [TryScatter] declines to launch a task that would exceed it. A
+// non-positive window means no bound.
+func WithOrderedGather(window int) JobOption {
+	return jobOptionFunc(func(j *Job) {
+		j.ordered = true
+		j.orderedWindow = window
+	})
+}
+
+// WithResultBuffer returns a [JobOption] that lets the results of up to size
+// finished tasks wait to be gathered without tying up the goroutines that ran
+// them. Without a buffer, a task's goroutine (or worker, see [WithWorkers])
+// waits until the task's result is gathered, so that each result waiting to be
+// gathered costs a goroutine. A task whose result does not fit in the buffer
+// still waits in this way.
+//
+// A task's weight is released from its pool's limit when the task finishes,
+// whether or not its result is buffered, so buffered results do not count
+// against any pool's limit; the buffer instead bounds the number of results
+// held in memory without a goroutine. Buffered results are gathered in the
+// same order as they would be otherwise (see [WithPriority] and
+// [WithOrderedGather]), and are discarded if the job is canceled, in which
+// case observers are notified by the goroutine that next tries to gather or by
+// [Job.CancelAndWait] (see [PoolObserver]).
+func WithResultBuffer(size int) JobOption {
+	return jobOptionFunc(func(j *Job) {
+		j.resultBuffer = size
+	})
+}
+
+// WithFailFast returns a [JobOption] that makes the first non-nil error
+// returned by any [TaskFunc] in the job fatal to the job, much like
+// [errgroup.WithContext]. When a task fails, the job is canceled as if by
+// [Job.Cancel], and the task's error is returned by any outstanding or
+// subsequent calls to [Scatter] or the gathering methods of the job in place
+// of [context.Canceled]. The error is also available via [context.Cause] on
+// the context passed to each task function.
+//
+// This applies regardless of what the corresponding [GatherFunc] would have
+// done with the error; indeed, the GatherFunc of the failing task is typically
+// not called at all, since its result is forfeited by the cancellation. Errors
+// include those converted from panics by [WithPanicRecovery] and from per-task
+// timeouts, but only the final attempt of a task scattered [WithRetry] counts.
+//
+// [errgroup.WithContext]: https://pkg.go.dev/golang.org/x/sync/errgroup#WithContext
+func WithFailFast() JobOption {
+	return jobOptionFunc(func(j *Job) {
+		j.failFast = true
+	})
+}
+
 type taskContextMarkerType struct{}
 
 var taskContextMarkerKey any = taskContextMarkerType{}
@@ -79,6 +219,33 @@ func (j *Job) isTaskContext(ctx context.Context) bool {
 	return isJobContext(ctx, j, taskContextMarkerKey)
 }
 
+type gatherContextMarkerType struct{}
+
+var gatherContextMarkerKey any = gatherContextMarkerType{}
+
+// Blocked launches typically pass the same context again and again, so the
+// most recently marked one is cached.
+func (j *Job) makeGatherContext(ctx context.Context) context.Context {
+	if c := j.gatherContext.Load(); c != nil && c.parent == ctx {
+		return c.marked
+	}
+	marked := makeJobContext(ctx, j, gatherContextMarkerKey)
+	// Comparing contexts of types that are not comparable would panic.
+	if reflect.TypeOf(ctx).Comparable() {
+		j.gatherContext.Store(&markedContext{ctx, marked})
+	}
+	return marked
+}
+
+type markedContext struct {
+	parent context.Context
+	marked context.Context
+}
+
+func (j *Job) isGatherContext(ctx context.Context) bool {
+	return isJobContext(ctx, j, gatherContextMarkerKey)
+}
+
 func makeJobContext[K any](ctx context.Context, j *Job, key K) context.Context {
 	// Accumulate the jobs to which the context belongs but avoid creating a
 	// collection unless it's needed.
@@ -121,6 +288,49 @@ func isJobContext[K any](ctx context.Context, j *Job, key K) bool {
 	}
 }
 
+// ErrPoolRemoved is returned by [Scatter] if the pool is removed from its job
+// by [Job.RemovePool] while Scatter is waiting to launch a task into it.
+var ErrPoolRemoved = errors.New("pool removed from job")
+
+// AddPool binds the given pool to the job, as if it had been passed to
+// [NewJob], so that tasks may then be launched into it. It is thread-safe and
+// may be called at any time, for instance from a [GatherFunc] that discovers a
+// new kind of work.
+//
+// Like NewJob, AddPool panics if the pool is already bound to a job.
+func (j *Job) AddPool(p *Pool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	p.applyToJob(j)
+	p.bindObservers(j)
+}
+
+// RemovePool unbinds the given pool from the job, after which [Scatter] will
+// panic if called with it (as for any unbound pool) and any calls to Scatter
+// that were blocked waiting to launch a task into it will return
+// [ErrPoolRemoved]. It is thread-safe and may be called at any time.
+//
+// Tasks already launched into the pool are unaffected: they continue to run,
+// are gathered by the job as usual, and continue to occupy the pool's capacity
+// until they finish. Once removed, the pool may be added to a job again with
+// [Job.AddPool], and the statistics of the job (see [Job.Stats]) no longer
+// include it. If the pool is the parent of other pools bound to the job (see
+// [WithParent]), launches into those pools will panic until the parent is
+// added back.
+//
+// RemovePool panics if the pool is not bound to the job.
+func (j *Job) RemovePool(p *Pool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	i := slices.Index(j.pools, p)
+	if i < 0 || !p.job.CompareAndSwap(j, nil) {
+		panic("pool not bound to this job")
+	}
+	j.pools = slices.Delete(j.pools, i, i+1)
+	// Wake any launches blocked on the pool so that they notice its removal.
+	p.notify()
+}
+
 // Cancel terminates any in-flight tasks and forfeits any ungathered results.
 // Outstanding calls to [Scatter], [Job.GatherOne], [Job.TryGatherOne],
 // [Job.GatherAll], or [Job.TryGatherAll] using the job or any of its pools will
@@ -138,14 +348,44 @@ func isJobContext[K any](ctx context.Context, j *Job, key K) bool {
 // Cancel is always thread-safe and calling it more than once has no additional
 // effect.
 func (j *Job) Cancel() {
-	j.cancelFunc()
+	j.cancelFunc(nil)
+}
+
+// Cancels the job due to the given task error if the job is in fail-fast mode.
+func (j *Job) taskFailed(err error) {
+	if j.failFast {
+		j.cancelFunc(err)
+	}
+}
+
+// Returns the error explaining why the job's context is done, which may be a
+// task error if the job was canceled by WithFailFast.
+func (j *Job) err() error {
+	return context.Cause(j.ctx)
 }
 
 // CancelAndWait cancels like [Job.Cancel], but then blocks until any
-// outstanding task goroutines exit.
+// outstanding task goroutines exit. The job's pools are then unbound from it,
+// such that they may be used in another job.
 func (j *Job) CancelAndWait() {
 	j.Cancel()
 	j.wg.Wait()
+	j.discardReady()
+	j.detachPools()
+}
+
+// Unbinds the job's pools from it so that they may be bound to another job.
+// The pools remain in j.pools so that the job's final statistics may still be
+// reported.
+func (j *Job) detachPools() {
+	j.mu.Lock()
+	for _, p := range j.pools {
+		p.job.CompareAndSwap(j, nil)
+	}
+	j.mu.Unlock()
+	for _, kp := range j.keyedPools {
+		kp.detach(j)
+	}
 }
 
 // GatherOne processes at most a single result from a task previously launched
@@ -162,6 +402,10 @@ func (j *Job) CancelAndWait() {
 //   - false, nil: there were no tasks in flight
 //   - false, non-nil: the argument or job-internal context was canceled
 //
+// Completed tasks are gathered in order of priority (see [WithPriority]) and
+// otherwise in the order in which they completed, unless the job was created
+// with [WithOrderedGather].
+//
 // If all gather functions are thread-safe, then GatherOne is thread-safe and
 // may be called concurrently from multiple goroutines. Blocking and
 // non-blocking calls may also be mixed, as can calls to any of the other gather
@@ -186,34 +430,332 @@ func (j *Job) TryGatherOne(ctx context.Context) (bool, error) {
 }
 
 func (j *Job) gatherOne(ctx context.Context, block bool) (bool, error) {
-	if block {
-		select {
-		case gather := <-j.gatherChannel:
+	for {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		if j.ctx.Err() != nil {
+			return false, j.err()
+		}
+		if gather := j.popGather(); gather != nil {
 			return true, j.executeGather(ctx, gather)
+		}
+		if !block {
+			// There were no in-flight tasks ready to gather.
+			return false, nil
+		}
+		select {
+		case rg := <-j.gathers:
+			return true, j.executeGather(ctx, j.took(rg))
+		case <-j.readySignal:
 		case <-ctx.Done():
 			return false, ctx.Err()
 		case <-j.ctx.Done():
-			return false, j.ctx.Err()
+			return false, j.err()
 		case <-j.done:
 			return false, nil
 		}
-	} else {
-		// Identical to the blocking branch above except replaces <-j.done with
-		// a default clause.
+	}
+}
+
+// Gathers a single result if one is ready, or else waits until one of the
+// given channels (obtained from Pool.awaitChange and Job.awaitWindowChange) is
+// closed and then gathers a result if one has become ready. Used by Scatter to
+// apply backpressure: a blocked launch must gather results as they arrive, but
+// must also re-check its pool's capacity whenever it changes for other reasons.
+func (j *Job) gatherOrWait(ctx context.Context, changed, windowChanged <-chan struct{}) (bool, error) {
+	for waited := false; ; waited = true {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		if j.ctx.Err() != nil {
+			return false, j.err()
+		}
+		// Mark the context so that launches from within the gather function
+		// do not wait for the blocked launch calling this.
+		if gather := j.popGather(); gather != nil {
+			return true, j.executeGather(j.makeGatherContext(ctx), gather)
+		}
+		if waited {
+			return false, nil
+		}
 		select {
-		case gather := <-j.gatherChannel:
-			return true, j.executeGather(ctx, gather)
+		case rg := <-j.gathers:
+			return true, j.executeGather(j.makeGatherContext(ctx), j.took(rg))
+		case <-j.readySignal:
+		case <-changed:
+		case <-windowChanged:
 		case <-ctx.Done():
 			return false, ctx.Err()
 		case <-j.ctx.Done():
-			return false, j.ctx.Err()
-		case <-j.done:
-			return false, nil
+			return false, j.err()
+		}
+	}
+}
+
+// The gather of a completed task, waiting to be taken by a gatherer.
+type readyGather struct {
+	pool     *Pool
+	gather   boundGatherFunc
+	priority int
+	seq      uint64
+	// Closed when the gather is taken from the ready queue, or nil if the
+	// gather was buffered instead of being waited for (see WithResultBuffer).
+	taken chan struct{}
+	// For a buffered gather, notifies observers that it was discarded.
+	discard func()
+}
+
+// Records that the given gather has been received from the gathers channel,
+// and returns its function.
+func (j *Job) took(rg readyGather) boundGatherFunc {
+	rg.pool.gatherTaken()
+	// A goroutine signaled that the ready queue had a gather may have taken
+	// this one instead, so pass the signal on.
+	if j.readyLen.Load() > 0 {
+		j.signalReady()
+	}
+	return rg.gather
+}
+
+// Marks the gather as taken from the ready queue by a gatherer.
+func (j *Job) takeLocked(rg *readyGather) {
+	rg.pool.gatherTaken()
+	j.readyLen.Add(-1)
+	if rg.taken != nil {
+		close(rg.taken)
+	} else {
+		j.buffered--
+	}
+}
+
+// Empties the ready queue after the job has been canceled, returning the
+// discard functions of any buffered gathers. The goroutines waiting on the
+// other gathers notice the cancellation themselves.
+func (j *Job) drainReadyLocked() []func() {
+	var discards []func()
+	for heap.Len(&j.ready) > 0 {
+		rg, _ := heap.PopOrderable(&j.ready)
+		if rg.taken == nil {
+			discards = append(discards, rg.discard)
+		}
+	}
+	j.readyLen.Store(0)
+	j.buffered = 0
+	return discards
+}
+
+// Drains the ready queue of a canceled job and notifies observers of the
+// discarded buffered gathers.
+func (j *Job) discardReady() {
+	j.mu.Lock()
+	discards := j.drainReadyLocked()
+	j.mu.Unlock()
+	for _, discard := range discards {
+		discard()
+	}
+}
+
+// Orders ready gathers by priority and then by the order in which they were
+// posted, such that the greatest is the one to gather next.
+func (a *readyGather) Cmp(b *readyGather) int {
+	if c := cmp.Compare(a.priority, b.priority); c != 0 {
+		return c
+	}
+	return cmp.Compare(b.seq, a.seq)
+}
+
+// Reserves a sequence number for a new task, provided that the given function
+// succeeds in acquiring capacity for it. Outside of ordered mode, sequence
+// numbers are not needed and the returned value is always zero.
+func (j *Job) reserveSeq(acquire func() bool) (uint64, bool) {
+	if !j.ordered {
+		return 0, acquire()
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.orderedWindow > 0 && j.readySeq-j.gatherSeq >= uint64(j.orderedWindow) {
+		return 0, false
+	}
+	if !acquire() {
+		return 0, false
+	}
+	seq := j.readySeq
+	j.readySeq++
+	return seq, true
+}
+
+// Posts a completed task's gather, then waits until it has been taken by a
+// gatherer or the job has been canceled, unless there is room to buffer it
+// (see WithResultBuffer). Also wakes any launches waiting for the capacity
+// released by the task. The sequence number is the one reserved for the task
+// by reserveSeq. Returns false if the gather was discarded due to
+// cancellation; the discard function, which is needed only if the job has a
+// result buffer, is called instead if the gather is discarded after being
+// buffered.
+func (j *Job) postGather(p *Pool, gather boundGatherFunc, priority int, seq uint64, discard func()) bool {
+	rg := readyGather{
+		pool:     p,
+		gather:   gather,
+		priority: priority,
+		seq:      seq,
+	}
+	if j.ordered || priority != 0 || j.resultBuffer > 0 {
+		return j.postReady(rg, discard)
+	}
+	return j.handOver(rg)
+}
+
+// Hands the gather directly to a goroutine waiting to gather, once there is
+// one. Returns false if the job was canceled first.
+func (j *Job) handOver(rg readyGather) bool {
+	// Waking waiting launches first would draw a blocked launch away from the
+	// gathers channel, so try to hand the gather to a waiting gatherer first.
+	select {
+	case j.gathers <- rg:
+		rg.pool.wakeWaiters()
+		return true
+	default:
+	}
+	rg.pool.wakeWaiters()
+	select {
+	case j.gathers <- rg:
+		return true
+	case <-j.ctx.Done():
+		return false
+	}
+}
+
+// Adds the gather to the ready queue and waits for it to be taken, unless it
+// can be buffered or handed over directly instead.
+func (j *Job) postReady(rg readyGather, discard func()) bool {
+	j.mu.Lock()
+	if j.buffered < j.resultBuffer && j.ctx.Err() == nil {
+		j.buffered++
+		rg.discard = discard
+	} else if !j.ordered && rg.priority == 0 {
+		j.mu.Unlock()
+		return j.handOver(rg)
+	} else {
+		rg.taken = make(chan struct{})
+	}
+	if j.ordered {
+		// Order purely by sequence number.
+		rg.priority = 0
+	} else {
+		j.readySeq++
+		rg.seq = j.readySeq
+	}
+	heap.PushOrderable(&j.ready, rg)
+	j.readyLen.Add(1)
+	j.signalReadyLocked()
+	j.mu.Unlock()
+	rg.pool.wakeWaiters()
+	if rg.taken == nil {
+		return true
+	}
+
+	select {
+	case <-rg.taken:
+		return true
+	case <-j.ctx.Done():
+	}
+
+	// The gather might have been taken just before the job was canceled, but
+	// not after, since popGather checks for cancellation under the same lock.
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	select {
+	case <-rg.taken:
+		return true
+	default:
+		return false
+	}
+}
+
+// Signals a goroutine waiting to gather if the ready queue has a gather that
+// may be taken.
+func (j *Job) signalReady() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.signalReadyLocked()
+}
+
+func (j *Job) signalReadyLocked() {
+	if rg, ok := heap.Peek(&j.ready); ok && (!j.ordered || rg.seq == j.gatherSeq) {
+		select {
+		case j.readySignal <- struct{}{}:
 		default:
-			// There were no in-flight tasks ready to gather.
-			return false, nil
+			// A signal is already pending.
+		}
+	}
+}
+
+// Removes and returns the next gather, if one is ready. Ready gathers of
+// higher priority are taken before those handed over directly, which have the
+// default priority, and those of lower priority after.
+func (j *Job) popGather() boundGatherFunc {
+	if j.ctx.Err() != nil {
+		// The job was canceled and any remaining gathers discarded.
+		if j.readyLen.Load() > 0 {
+			j.discardReady()
 		}
+		return nil
+	}
+	if j.readyLen.Load() > 0 {
+		if gather := j.popReady(0); gather != nil {
+			return gather
+		}
+	}
+	select {
+	case rg := <-j.gathers:
+		return j.took(rg)
+	default:
+	}
+	if j.readyLen.Load() > 0 {
+		return j.popReady(math.MinInt)
 	}
+	return nil
+}
+
+// Removes and returns the next gather from the ready queue, provided that it
+// may be gathered next and has at least the given priority.
+func (j *Job) popReady(minPriority int) boundGatherFunc {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.ctx.Err() != nil {
+		return nil
+	}
+	rg, ok := heap.Peek(&j.ready)
+	if !ok || rg.priority < minPriority || j.ordered && rg.seq != j.gatherSeq {
+		return nil
+	}
+	_, _ = heap.PopOrderable(&j.ready)
+	j.takeLocked(&rg)
+	if j.ordered {
+		// Make room in the ordering window for blocked launches.
+		j.gatherSeq++
+		if j.windowChanged != nil {
+			close(j.windowChanged)
+			j.windowChanged = nil
+		}
+	}
+	j.signalReadyLocked()
+	return rg.gather
+}
+
+// In ordered mode, returns a channel that will be closed the next time room
+// may become available in the ordering window. Otherwise, returns nil.
+func (j *Job) awaitWindowChange() <-chan struct{} {
+	if !j.ordered {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.windowChanged == nil {
+		j.windowChanged = make(chan struct{})
+	}
+	return j.windowChanged
 }
 
 // GatherAll processes all results from previously scattered tasks, continuing
@@ -221,7 +763,8 @@ func (j *Job) gatherOne(ctx context.Context, block bool) (bool, error) {
 // wait for in-flight tasks that are not yet complete.
 //
 // Returns nil unless the context is canceled or a task's [GatherFunc] returns a
-// non-nil error.
+// non-nil error. See [WithErrorAggregation] for a mode in which gather errors
+// do not stop GatherAll.
 //
 // If all gather functions are thread-safe, then GatherAll is thread-safe and
 // can be called concurrently from multiple goroutines. In this case they will
@@ -252,10 +795,14 @@ func (j *Job) gatherAll(ctx context.Context, block bool) error {
 	for {
 		ok, err := j.gatherOne(ctx, block)
 		if err != nil {
-			return err
+			// In error aggregation mode, keep going after gather errors.
+			if ok && j.errs.collect(err) {
+				continue
+			}
+			return j.errs.take(err)
 		}
 		if !ok {
-			return nil
+			return j.errs.take(nil)
 		}
 	}
 }
@@ -275,23 +822,12 @@ func (j *Job) decrementInFlight() {
 			// the job was closed after the decrement AND another increment.
 			if !j.inFlight.GreaterThanZero() {
 				close(j.done)
+				j.detachPools()
 			}
 		}
 	}
 }
 
-// Wakes any goroutines that might be waiting on the gatherChannel.
-func (j *Job) wakeGatherers() {
-	for {
-		select {
-		case j.gatherChannel <- nil:
-		default:
-			// No more waiters
-			return
-		}
-	}
-}
-
 // Close must be called to signify that no more top-level tasks will be launched
 // and that [Job.GatherAll] should stop blocking to wait for more after the
 // results of all in-flight tasks have been gathered. See [Job.GatherAll] for
@@ -300,9 +836,12 @@ func (j *Job) wakeGatherers() {
 // Close may be called from any goroutine and may safely be called more than
 // once.
 func (j *Job) Close() {
-	j.closed.Store(true)
+	if !j.closed.Swap(true) {
+		j.jobClosed()
+	}
 	if !j.inFlight.GreaterThanZero() {
 		close(j.done)
+		j.detachPools()
 	}
 }
 
@@ -5,7 +5,12 @@ package psg_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/petenewcomb/psg-go"
 	"github.com/stretchr/testify/require"
@@ -26,3 +31,438 @@ func TestJobBoundPoolPanic(t *testing.T) {
 		_ = psg.NewJob(ctx, pool)
 	})
 }
+
+func TestJobPoolReuse(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(1, psg.WithPoolName("reused"))
+
+	task := func(ctx context.Context) (int, error) {
+		return 1, nil
+	}
+	sum := 0
+	gather := func(ctx context.Context, result int, err error) error {
+		sum += result
+		return err
+	}
+
+	// A pool is released once all of its job's tasks have been gathered.
+	job := psg.NewJob(ctx, pool)
+	chk.NoError(psg.Scatter(ctx, pool, task, gather))
+	chk.NoError(job.CloseAndGatherAll(ctx))
+	chk.PanicsWithValue("pool not bound to a job", func() {
+		_ = psg.Scatter(ctx, pool, task, gather)
+	})
+
+	// Or once its job has been canceled and its tasks have exited. Canceling
+	// releases the capacity of tasks that had not yet started, were still
+	// running, or were waiting to be gathered, so the pool can be reused
+	// repeatedly at the same limit.
+	const canceledJobs = 50
+	for range canceledJobs {
+		job = psg.NewJob(ctx, pool)
+		chk.NoError(psg.Scatter(ctx, pool, task, gather))
+		job.CancelAndWait()
+		chk.Zero(pool.Stats().Weight)
+	}
+
+	// The pool keeps its configuration and state.
+	job = psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+	chk.NoError(psg.Scatter(ctx, pool, task, gather))
+	chk.NoError(job.CloseAndGatherAll(ctx))
+	chk.Equal(2, sum)
+	chk.Equal("reused", pool.Name())
+	chk.Equal(1, pool.Limit())
+	chk.Equal(uint64(canceledJobs+2), pool.Stats().Launched)
+}
+
+func TestJobPoolReuseAfterCancel(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	const limit = 4
+	pool := psg.NewPool(limit)
+
+	blocking := func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+	quick := func(ctx context.Context) (int, error) {
+		return 1, nil
+	}
+	ignore := func(ctx context.Context, result int, err error) error {
+		return nil
+	}
+
+	// Cancel each job while its tasks are still pending: some are blocked
+	// running, and others may not have started yet or be waiting to be
+	// gathered.
+	for range 20 {
+		job := psg.NewJob(ctx, pool)
+		for i := range limit {
+			task := quick
+			if i%2 == 0 {
+				task = blocking
+			}
+			chk.NoError(psg.Scatter(ctx, pool, task, ignore))
+		}
+		job.CancelAndWait()
+		stats := pool.Stats()
+		chk.Zero(stats.InFlight)
+		chk.Zero(stats.Weight)
+	}
+
+	// The pool still launches up to its full limit.
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+	for range limit {
+		ok, err := psg.TryScatter(ctx, pool, blocking, ignore)
+		chk.NoError(err)
+		chk.True(ok)
+	}
+}
+
+func TestJobFailFast(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(-1)
+	job := psg.NewJob(ctx, pool, psg.WithFailFast())
+	defer job.CancelAndWait()
+
+	// Gather functions that swallow errors must not prevent the failure from
+	// being reported.
+	ignoreErr := func(ctx context.Context, result int, err error) error {
+		return nil
+	}
+
+	errFailed := errors.New("failed")
+	started := make(chan struct{})
+	blockedErr := make(chan error, 1)
+	chk.NoError(psg.Scatter(ctx, pool,
+		func(ctx context.Context) (int, error) {
+			close(started)
+			<-ctx.Done()
+			blockedErr <- context.Cause(ctx)
+			return 0, ctx.Err()
+		},
+		ignoreErr,
+	))
+	<-started
+	chk.NoError(psg.Scatter(ctx, pool,
+		func(ctx context.Context) (int, error) {
+			return 0, errFailed
+		},
+		ignoreErr,
+	))
+
+	chk.ErrorIs(job.CloseAndGatherAll(ctx), errFailed)
+	chk.ErrorIs(<-blockedErr, errFailed)
+
+	// Further scattering is refused with the same error.
+	chk.ErrorIs(psg.Scatter(ctx, pool,
+		func(ctx context.Context) (int, error) {
+			return 0, nil
+		},
+		ignoreErr,
+	), errFailed)
+}
+
+func TestJobWithoutFailFastIgnoresTaskErrors(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pools := []*psg.Pool{psg.NewPool(1), psg.NewPool(1)}
+	job := psg.NewJob(ctx, psg.WithPools(pools...))
+	defer job.CancelAndWait()
+
+	for _, pool := range pools {
+		chk.NoError(psg.Scatter(ctx, pool,
+			func(ctx context.Context) (int, error) {
+				return 0, errors.New("ignored")
+			},
+			func(ctx context.Context, result int, err error) error {
+				chk.Error(err)
+				return nil
+			},
+		))
+	}
+	chk.NoError(job.CloseAndGatherAll(ctx))
+}
+
+func TestJobErrorAggregation(t *testing.T) {
+	for _, limit := range []int{0, 2} {
+		t.Run(fmt.Sprintf("limit=%d", limit), func(t *testing.T) {
+			chk := require.New(t)
+			ctx := context.Background()
+			pool := psg.NewPool(1)
+			job := psg.NewJob(ctx, pool, psg.WithErrorAggregation(limit))
+			defer job.CancelAndWait()
+
+			// With a limit of one, every Scatter after the first must gather
+			// the previous failure, which must not cause Scatter to fail.
+			const taskCount = 5
+			var errs []error
+			for i := range taskCount {
+				errs = append(errs, fmt.Errorf("error %d", i))
+				chk.NoError(psg.Scatter(ctx, pool,
+					func(ctx context.Context) (int, error) {
+						return i, nil
+					},
+					func(ctx context.Context, result int, err error) error {
+						return errs[result]
+					},
+				))
+			}
+			err := job.CloseAndGatherAll(ctx)
+
+			var ae *psg.AggregateError
+			chk.ErrorAs(err, &ae)
+			if limit > 0 {
+				chk.Equal(errs[:limit], ae.Errors)
+				chk.Equal(taskCount-limit, ae.Omitted)
+				chk.ErrorIs(err, errs[0])
+				chk.NotErrorIs(err, errs[taskCount-1])
+			} else {
+				chk.Equal(errs, ae.Errors)
+				chk.Zero(ae.Omitted)
+				for _, e := range errs {
+					chk.ErrorIs(err, e)
+				}
+			}
+
+			// The errors were reported, so they are not reported again.
+			chk.NoError(job.GatherAll(ctx))
+		})
+	}
+}
+
+func TestJobOrderedGather(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(-1)
+	job := psg.NewJob(ctx, pool, psg.WithOrderedGather(0))
+	defer job.CancelAndWait()
+
+	// Later tasks complete sooner.
+	const taskCount = 5
+	var gathered []int
+	for i := range taskCount {
+		chk.NoError(psg.Scatter(ctx, pool,
+			func(ctx context.Context) (int, error) {
+				time.Sleep(time.Duration(taskCount-i) * 2 * time.Millisecond)
+				return i, nil
+			},
+			func(ctx context.Context, result int, err error) error {
+				gathered = append(gathered, result)
+				return err
+			},
+			// Priority must not affect the order of gathering.
+			psg.WithPriority(i),
+		))
+	}
+	chk.NoError(job.CloseAndGatherAll(ctx))
+	chk.Equal([]int{0, 1, 2, 3, 4}, gathered)
+}
+
+func TestJobOrderedGatherWindow(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(-1)
+	job := psg.NewJob(ctx, pool, psg.WithOrderedGather(2))
+	defer job.CancelAndWait()
+
+	release := make(chan struct{})
+	var gathered []int
+	newTask := func(i int) psg.TaskFunc[int] {
+		return func(ctx context.Context) (int, error) {
+			<-release
+			return i, nil
+		}
+	}
+	gather := func(ctx context.Context, result int, err error) error {
+		gathered = append(gathered, result)
+		return err
+	}
+
+	for i := range 2 {
+		ok, err := psg.TryScatter(ctx, pool, newTask(i), gather)
+		chk.NoError(err)
+		chk.True(ok)
+	}
+
+	// The window is full even though the pool is unlimited.
+	ok, err := psg.TryScatter(ctx, pool, newTask(2), gather)
+	chk.NoError(err)
+	chk.False(ok)
+
+	// A blocking Scatter gathers the first result to make room.
+	close(release)
+	chk.NoError(psg.Scatter(ctx, pool, newTask(2), gather))
+	chk.Equal([]int{0}, gathered)
+
+	chk.NoError(job.CloseAndGatherAll(ctx))
+	chk.Equal([]int{0, 1, 2}, gathered)
+}
+
+func TestJobAddAndRemovePool(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	job := psg.NewJob(ctx)
+	defer job.CancelAndWait()
+
+	release := make(chan struct{})
+	task := func(ctx context.Context) (int, error) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return 1, nil
+	}
+	sum := 0
+	gather := func(ctx context.Context, result int, err error) error {
+		sum += result
+		return err
+	}
+
+	// A pool added to a live job can be used like any other.
+	pool := psg.NewPool(2)
+	job.AddPool(pool)
+	chk.NoError(psg.Scatter(ctx, pool, task, gather))
+	chk.Equal(uint64(1), job.Stats().Launched)
+	chk.PanicsWithValue("pool was already registered", func() {
+		job.AddPool(pool)
+	})
+
+	// Once removed, no more tasks may be launched into it, but its in-flight
+	// task is still gathered by the job.
+	job.RemovePool(pool)
+	chk.PanicsWithValue("pool not bound to a job", func() {
+		_ = psg.Scatter(ctx, pool, task, gather)
+	})
+	chk.PanicsWithValue("pool not bound to this job", func() {
+		job.RemovePool(pool)
+	})
+	close(release)
+	ok, err := job.GatherOne(ctx)
+	chk.NoError(err)
+	chk.True(ok)
+	chk.Equal(1, sum)
+
+	// The removed pool may be added back.
+	job.AddPool(pool)
+	chk.NoError(psg.Scatter(ctx, pool, task, gather))
+	chk.NoError(job.CloseAndGatherAll(ctx))
+	chk.Equal(2, sum)
+}
+
+func TestJobRemovePoolUnblocksScatter(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(1)
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	release := make(chan struct{})
+	task := func(ctx context.Context) (int, error) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return 0, nil
+	}
+	gather := func(ctx context.Context, result int, err error) error {
+		return err
+	}
+	chk.NoError(psg.Scatter(ctx, pool, task, gather))
+
+	errCh := make(chan error)
+	go func() {
+		errCh <- psg.Scatter(ctx, pool, task, gather)
+	}()
+	chk.Eventually(func() bool {
+		return pool.Stats().Blocked == 1
+	}, time.Second, time.Millisecond)
+	job.RemovePool(pool)
+	chk.ErrorIs(<-errCh, psg.ErrPoolRemoved)
+
+	close(release)
+	chk.NoError(job.CloseAndGatherAll(ctx))
+}
+
+func TestJobResultBuffer(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	goroutines := runtime.NumGoroutine()
+	pool := psg.NewPool(-1)
+	job := psg.NewJob(ctx, pool, psg.WithResultBuffer(2))
+	defer job.CancelAndWait()
+
+	var finished atomic.Int64
+	sum := 0
+	for i := range 3 {
+		chk.NoError(psg.Scatter(ctx, pool,
+			func(ctx context.Context) (int, error) {
+				finished.Add(1)
+				return i, nil
+			},
+			func(ctx context.Context, result int, err error) error {
+				// Every task finished without waiting for a gather.
+				chk.Equal(int64(3), finished.Load())
+				sum += result
+				return err
+			},
+		))
+	}
+
+	// The goroutines of the tasks whose results fit in the buffer exit
+	// without waiting for them to be gathered, leaving only the one whose
+	// result does not fit.
+	chk.Eventually(func() bool {
+		return job.Stats().Ready == 3
+	}, time.Second, time.Millisecond)
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > goroutines+1 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	chk.LessOrEqual(runtime.NumGoroutine(), goroutines+1)
+	chk.Equal(0, sum)
+
+	chk.NoError(job.CloseAndGatherAll(ctx))
+	chk.Equal(3, sum)
+}
+
+func BenchmarkGather(b *testing.B) {
+	for _, bm := range []struct {
+		name     string
+		jobOpts  []psg.JobOption
+		taskOpts []psg.ScatterOption
+	}{
+		// Without priorities, ordering, or buffering, gathers are handed
+		// directly to gatherers.
+		{"default", nil, nil},
+		{"priority", nil, []psg.ScatterOption{psg.WithPriority(1)}},
+		{"ordered", []psg.JobOption{psg.WithOrderedGather(0)}, nil},
+		{"buffered", []psg.JobOption{psg.WithResultBuffer(16)}, nil},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			ctx := context.Background()
+			pool := psg.NewPool(runtime.GOMAXPROCS(0))
+			job := psg.NewJob(ctx, append([]psg.JobOption{pool}, bm.jobOpts...)...)
+			defer job.CancelAndWait()
+
+			task := func(ctx context.Context) (int, error) {
+				return 1, nil
+			}
+			gather := func(ctx context.Context, result int, err error) error {
+				return err
+			}
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := psg.Scatter(ctx, pool, task, gather, bm.taskOpts...); err != nil {
+					b.Fatal(err)
+				}
+			}
+			if err := job.CloseAndGatherAll(ctx); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,348 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A KeyedPool is a set of pools, one per key, that are created on demand as
+// tasks are launched with [ScatterKeyed] or [TryScatterKeyed] and that all
+// share the same limit. It is useful when the keys are not known in advance,
+// for instance to limit the number of concurrent requests to each host visited
+// by a web crawler, or the number of concurrent tasks run on behalf of each
+// tenant of a multi-tenant service.
+//
+// A key's pool is evicted once it has been idle, meaning that all tasks
+// launched into it have been gathered and no launches into it are pending,
+// for the duration given by [WithIdleTimeout] (by default, as soon as it
+// becomes idle). An evicted pool is replaced by a fresh one the next time a
+// task is launched with its key. Per-pool state such as that of a rate limit
+// (see [WithRateLimit]) is therefore lost on eviction, and an idle timeout at
+// least as long as it takes the rate limiter to refill is recommended in that
+// case.
+//
+// Like a [Pool], a KeyedPool must be bound to a [Job] before a task can be
+// launched into it; each *KeyedPool is a [JobOption] for this purpose. Once the
+// job has finished, the KeyedPool may be bound to another job, and the pools
+// of any keys not yet evicted are carried over to it.
+type KeyedPool[K comparable] struct {
+	limit       atomic.Int64
+	opts        []PoolOption
+	idleTimeout time.Duration
+
+	// Protects the fields below.
+	mu  sync.Mutex
+	job *Job
+	// The current pool for each key.
+	pools map[K]*keyedPoolEntry
+	// Totals from pools that have been evicted, for Stats.
+	evictedLaunched uint64
+	evictedGathered uint64
+}
+
+type keyedPoolEntry struct {
+	pool *Pool
+	// The number of launches into the pool that are pending or whose tasks
+	// have not yet been gathered.
+	refs int
+	// Incremented each time the pool is used, to cancel pending evictions.
+	gen       uint64
+	idleTimer *time.Timer
+}
+
+// NewKeyedPool creates a new [KeyedPool] whose per-key pools each have the
+// given limit. See [Pool.SetLimit] for the range of allowed values and their
+// semantics.
+func NewKeyedPool[K comparable](limit int, opts ...KeyedPoolOption) *KeyedPool[K] {
+	var cfg keyedPoolConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	kp := &KeyedPool[K]{
+		opts:        cfg.poolOpts,
+		idleTimeout: cfg.idleTimeout,
+	}
+	kp.limit.Store(int64(limit))
+	return kp
+}
+
+// A KeyedPoolOption configures a [KeyedPool] at creation time. See
+// [NewKeyedPool].
+type KeyedPoolOption func(*keyedPoolConfig)
+
+type keyedPoolConfig struct {
+	poolOpts    []PoolOption
+	idleTimeout time.Duration
+}
+
+// WithSubPoolOptions returns a [KeyedPoolOption] that applies the given pool
+// options to each of the pools created by the [KeyedPool]. For instance,
+// [WithParent] may be used to bound the total across all keys in addition to
+// the limit for each key, in which case each key's pool is added to the
+// parent's children when created and removed when evicted.
+func WithSubPoolOptions(opts ...PoolOption) KeyedPoolOption {
+	return func(cfg *keyedPoolConfig) {
+		cfg.poolOpts = append(cfg.poolOpts, opts...)
+	}
+}
+
+// WithIdleTimeout returns a [KeyedPoolOption] that delays the eviction of each
+// key's pool until it has been idle for the given duration.
+func WithIdleTimeout(d time.Duration) KeyedPoolOption {
+	return func(cfg *keyedPoolConfig) {
+		cfg.idleTimeout = d
+	}
+}
+
+// Limit returns the current limit of each key's pool. See
+// [KeyedPool.SetLimit].
+func (kp *KeyedPool[K]) Limit() int {
+	return int(kp.limit.Load())
+}
+
+// SetLimit sets the limit of the pool for each key, including those not yet
+// created. See [Pool.SetLimit] for the range of allowed values and their
+// semantics. Like Pool.SetLimit, it is always thread-safe.
+func (kp *KeyedPool[K]) SetLimit(limit int) {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+	kp.limit.Store(int64(limit))
+	for _, e := range kp.pools {
+		e.pool.SetLimit(limit)
+	}
+}
+
+// Len returns the number of keys that currently have a pool.
+func (kp *KeyedPool[K]) Len() int {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+	return len(kp.pools)
+}
+
+// Stats returns a consistent snapshot of the state of the keyed pool, summing
+// the statistics of the pools of all of its keys (see [Pool.Stats]). Launched
+// and Gathered include the tasks of pools that have since been evicted.
+func (kp *KeyedPool[K]) Stats() PoolStats {
+	pools, unlock := kp.lockStats()
+	defer unlock()
+	s := PoolStats{
+		Limit:    kp.Limit(),
+		Launched: kp.evictedLaunched,
+		Gathered: kp.evictedGathered,
+	}
+	for _, p := range pools {
+		ps := p.statsLocked()
+		s.InFlight += ps.InFlight
+		s.Weight += ps.Weight
+		s.Blocked += ps.Blocked
+		s.Launched += ps.Launched
+		s.Gathered += ps.Gathered
+	}
+	return s
+}
+
+// The methods of KeyedPool used by the job to which it is bound.
+type boundKeyedPool interface {
+	lockJobStats() (JobStats, func())
+	detach(j *Job)
+}
+
+// Locks the keyed pool and the statistics of the pools of all of its keys,
+// returning those pools and a function that unlocks them.
+func (kp *KeyedPool[K]) lockStats() ([]*Pool, func()) {
+	kp.mu.Lock()
+	pools := make([]*Pool, 0, len(kp.pools))
+	for _, e := range kp.pools {
+		pools = append(pools, e.pool)
+	}
+	unlock := lockStats(pools)
+	return pools, func() {
+		unlock()
+		kp.mu.Unlock()
+	}
+}
+
+// Like lockStats, but returns the contribution of the keyed pool to the
+// statistics of its job (see Job.Stats).
+func (kp *KeyedPool[K]) lockJobStats() (JobStats, func()) {
+	pools, unlock := kp.lockStats()
+	s := JobStats{
+		Launched: kp.evictedLaunched,
+		Gathered: kp.evictedGathered,
+	}
+	for _, p := range pools {
+		s.add(p.jobStatsLocked())
+	}
+	return s, unlock
+}
+
+// Binds the keyed pool to the given job, making it usable as a JobOption.
+func (kp *KeyedPool[K]) applyToJob(j *Job) {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+	if kp.job != nil {
+		panic("pool was already registered")
+	}
+	kp.job = j
+	j.keyedPools = append(j.keyedPools, kp)
+	// Carry over the pools of keys used in a previous job.
+	for _, e := range kp.pools {
+		e.pool.job.Store(j)
+		e.pool.bindObservers(j)
+	}
+}
+
+// Unbinds the keyed pool from the given job once the job has finished.
+func (kp *KeyedPool[K]) detach(j *Job) {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+	if kp.job != j {
+		return
+	}
+	kp.job = nil
+	for key, e := range kp.pools {
+		e.pool.job.CompareAndSwap(j, nil)
+		// Results forfeited by cancellation of the job will never be
+		// gathered, so release the references held for them.
+		if e.refs > 0 {
+			e.refs = 1
+			kp.releaseLocked(key, e)
+		}
+	}
+}
+
+// Returns the pool for the given key, creating it if necessary, and holds a
+// reference to it that prevents its eviction until released.
+func (kp *KeyedPool[K]) acquire(key K) *keyedPoolEntry {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+	j := kp.job
+	if j == nil {
+		panic("pool not bound to a job")
+	}
+	e := kp.pools[key]
+	if e == nil {
+		p := NewPool(kp.Limit(), kp.opts...)
+		p.job.Store(j)
+		p.bindObservers(j)
+		e = &keyedPoolEntry{pool: p}
+		if kp.pools == nil {
+			kp.pools = make(map[K]*keyedPoolEntry)
+		}
+		kp.pools[key] = e
+	}
+	e.refs++
+	e.gen++
+	if e.idleTimer != nil {
+		e.idleTimer.Stop()
+		e.idleTimer = nil
+	}
+	return e
+}
+
+// Releases a reference taken by acquire, evicting the pool or scheduling its
+// eviction if it has become idle.
+func (kp *KeyedPool[K]) release(key K, e *keyedPoolEntry) {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+	kp.releaseLocked(key, e)
+}
+
+func (kp *KeyedPool[K]) releaseLocked(key K, e *keyedPoolEntry) {
+	if e.refs == 0 {
+		// Already released by detach.
+		return
+	}
+	e.refs--
+	if e.refs > 0 {
+		return
+	}
+	if kp.idleTimeout <= 0 {
+		kp.evictLocked(key, e)
+		return
+	}
+	gen := e.gen
+	e.idleTimer = time.AfterFunc(kp.idleTimeout, func() {
+		kp.mu.Lock()
+		defer kp.mu.Unlock()
+		// Skip the eviction if the pool was used in the meantime.
+		if e.refs == 0 && e.gen == gen {
+			kp.evictLocked(key, e)
+		}
+	})
+}
+
+func (kp *KeyedPool[K]) evictLocked(key K, e *keyedPoolEntry) {
+	if kp.pools[key] != e {
+		return
+	}
+	delete(kp.pools, key)
+	if parent := e.pool.parent; parent != nil {
+		parent.removeChild(e.pool)
+	}
+	kp.evictedLaunched += e.pool.launched.Load()
+	kp.evictedGathered += e.pool.gathered.Load()
+}
+
+// ScatterKeyed launches a task like [Scatter] into the pool of the given
+// [KeyedPool] for the given key, creating that pool if necessary.
+func ScatterKeyed[K comparable, T any](
+	ctx context.Context,
+	kp *KeyedPool[K],
+	key K,
+	taskFunc TaskFunc[T],
+	gatherFunc GatherFunc[T],
+	opts ...ScatterOption,
+) error {
+	_, err := scatterKeyed(ctx, kp, key, taskFunc, gatherFunc, true, opts)
+	return err
+}
+
+// TryScatterKeyed attempts to launch a task like [TryScatter] into the pool of
+// the given [KeyedPool] for the given key, creating that pool if necessary.
+func TryScatterKeyed[K comparable, T any](
+	ctx context.Context,
+	kp *KeyedPool[K],
+	key K,
+	taskFunc TaskFunc[T],
+	gatherFunc GatherFunc[T],
+	opts ...ScatterOption,
+) (bool, error) {
+	return scatterKeyed(ctx, kp, key, taskFunc, gatherFunc, false, opts)
+}
+
+func scatterKeyed[K comparable, T any](
+	ctx context.Context,
+	kp *KeyedPool[K],
+	key K,
+	taskFunc TaskFunc[T],
+	gatherFunc GatherFunc[T],
+	block bool,
+	opts []ScatterOption,
+) (bool, error) {
+	// Check the functions before acquiring a reference to the key's pool,
+	// which a panic in scatter would leak.
+	if taskFunc == nil {
+		panic("task function must be non-nil")
+	}
+	if gatherFunc == nil {
+		panic("gather function must be non-nil")
+	}
+	e := kp.acquire(key)
+	// Hold the reference until the task is gathered, so that the pool is not
+	// evicted while any of its capacity is in use.
+	gather := func(ctx context.Context, result T, err error) error {
+		kp.release(key, e)
+		return gatherFunc(ctx, result, err)
+	}
+	ok, err := scatter(ctx, e.pool, taskFunc, gather, block, opts)
+	if !ok {
+		kp.release(key, e)
+	}
+	return ok, err
+}
@@ -0,0 +1,234 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/petenewcomb/psg-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyedPool(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	global := psg.NewPool(3)
+	hosts := psg.NewKeyedPool[string](2, psg.WithSubPoolOptions(psg.WithParent(global)))
+	job := psg.NewJob(ctx, global, hosts)
+	defer job.CancelAndWait()
+
+	release := make(chan struct{})
+	task := func(ctx context.Context) (int, error) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return 0, nil
+	}
+	gathered := map[string]int{}
+	tryScatter := func(host string) bool {
+		ok, err := psg.TryScatterKeyed(ctx, hosts, host, task,
+			func(ctx context.Context, result int, err error) error {
+				gathered[host]++
+				return err
+			},
+		)
+		chk.NoError(err)
+		return ok
+	}
+
+	// Each key has its own limit.
+	chk.True(tryScatter("a"))
+	chk.True(tryScatter("a"))
+	chk.False(tryScatter("a"))
+	chk.True(tryScatter("b"))
+	chk.Equal(2, hosts.Len())
+
+	// Sub-pool options apply to each key's pool.
+	chk.False(tryScatter("c"))
+	chk.Equal(2, hosts.Len())
+
+	stats := hosts.Stats()
+	chk.Equal(2, stats.Limit)
+	chk.Equal(3, stats.InFlight)
+	chk.Equal(uint64(3), stats.Launched)
+
+	// Pools are evicted once all of their tasks have been gathered.
+	close(release)
+	chk.NoError(job.CloseAndGatherAll(ctx))
+	chk.Equal(map[string]int{"a": 2, "b": 1}, gathered)
+	chk.Equal(0, hosts.Len())
+	stats = hosts.Stats()
+	chk.Equal(0, stats.InFlight)
+	chk.Equal(uint64(3), stats.Launched)
+	chk.Equal(uint64(3), stats.Gathered)
+	chk.Equal(uint64(3), job.Stats().Gathered)
+}
+
+func TestKeyedPoolBusyParent(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	const limit = 4
+	global := psg.NewPool(limit)
+	hosts := psg.NewKeyedPool[int](1, psg.WithSubPoolOptions(psg.WithParent(global)))
+	job := psg.NewJob(ctx, global, hosts)
+	defer job.CancelAndWait()
+
+	var running, maxRunning atomic.Int64
+	task := func(ctx context.Context) (int, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(100 * time.Microsecond)
+		return 0, nil
+	}
+	gathered := 0
+	gather := func(ctx context.Context, result int, err error) error {
+		gathered++
+		return err
+	}
+
+	// Each key's pool is created as a child of the parent while the parent is
+	// running tasks of its own, and evicted again as soon as it is idle.
+	const n = 400
+	for i := range n {
+		if i%2 == 0 {
+			chk.NoError(psg.Scatter(ctx, global, task, gather))
+		} else {
+			chk.NoError(psg.ScatterKeyed(ctx, hosts, i%7, task, gather))
+		}
+	}
+	chk.NoError(job.CloseAndGatherAll(ctx))
+	chk.Equal(n, gathered)
+	chk.LessOrEqual(maxRunning.Load(), int64(limit))
+	chk.Equal(0, hosts.Len())
+	chk.Zero(global.Stats().Weight)
+}
+
+func TestKeyedPoolIdleTimeout(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	tenants := psg.NewKeyedPool[int](1, psg.WithIdleTimeout(200*time.Millisecond))
+	job := psg.NewJob(ctx, tenants)
+	defer job.CancelAndWait()
+
+	task := func(ctx context.Context) (int, error) {
+		return 0, nil
+	}
+	gather := func(ctx context.Context, result int, err error) error {
+		return err
+	}
+	for tenant := range 3 {
+		chk.NoError(psg.ScatterKeyed(ctx, tenants, tenant, task, gather))
+	}
+	chk.NoError(job.CloseAndGatherAll(ctx))
+
+	// The idle pools linger until the timeout.
+	chk.Equal(3, tenants.Len())
+	chk.Eventually(func() bool {
+		return tenants.Len() == 0
+	}, 5*time.Second, time.Millisecond)
+	chk.Equal(uint64(3), tenants.Stats().Gathered)
+}
+
+func TestKeyedPoolReuse(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	tenants := psg.NewKeyedPool[int](1, psg.WithIdleTimeout(time.Hour))
+
+	task := func(ctx context.Context) (int, error) {
+		return 0, nil
+	}
+	gather := func(ctx context.Context, result int, err error) error {
+		return err
+	}
+
+	job := psg.NewJob(ctx, tenants)
+	chk.NoError(psg.ScatterKeyed(ctx, tenants, 1, task, gather))
+	chk.NoError(job.CloseAndGatherAll(ctx))
+
+	// The pools of keys not yet evicted carry over to the next job.
+	job = psg.NewJob(ctx, tenants)
+	defer job.CancelAndWait()
+	chk.Equal(1, tenants.Len())
+	chk.NoError(psg.ScatterKeyed(ctx, tenants, 1, task, gather))
+	chk.NoError(psg.ScatterKeyed(ctx, tenants, 2, task, gather))
+	chk.NoError(job.CloseAndGatherAll(ctx))
+	chk.Equal(2, tenants.Len())
+	chk.Equal(uint64(3), tenants.Stats().Gathered)
+}
+
+func TestKeyedPoolSetLimit(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	keyed := psg.NewKeyedPool[string](1)
+	job := psg.NewJob(ctx, keyed)
+	defer job.CancelAndWait()
+
+	release := make(chan struct{})
+	task := func(ctx context.Context) (int, error) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return 0, nil
+	}
+	gather := func(ctx context.Context, result int, err error) error {
+		return err
+	}
+	tryScatter := func(key string) bool {
+		ok, err := psg.TryScatterKeyed(ctx, keyed, key, task, gather)
+		chk.NoError(err)
+		return ok
+	}
+
+	chk.True(tryScatter("a"))
+	chk.False(tryScatter("a"))
+
+	// The new limit applies to existing and new keys alike.
+	keyed.SetLimit(2)
+	chk.Equal(2, keyed.Limit())
+	chk.True(tryScatter("a"))
+	chk.True(tryScatter("b"))
+	chk.True(tryScatter("b"))
+	chk.False(tryScatter("b"))
+
+	close(release)
+	chk.NoError(job.CloseAndGatherAll(ctx))
+}
+
+func TestKeyedPoolUnboundPanic(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	keyed := psg.NewKeyedPool[string](1)
+	chk.PanicsWithValue("pool not bound to a job", func() {
+		_ = psg.ScatterKeyed(ctx, keyed, "a",
+			func(ctx context.Context) (int, error) { return 0, nil },
+			func(ctx context.Context, result int, err error) error { return nil },
+		)
+	})
+}
+
+func TestKeyedPoolNilTaskPanic(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	keyed := psg.NewKeyedPool[string](1)
+	job := psg.NewJob(ctx, keyed)
+	defer job.CancelAndWait()
+	chk.PanicsWithValue("task function must be non-nil", func() {
+		_ = psg.ScatterKeyed[string, int](ctx, keyed, "a", nil,
+			func(ctx context.Context, result int, err error) error { return nil },
+		)
+	})
+	// The panic did not leave a pool behind for the key.
+	chk.Equal(0, keyed.Len())
+}
@@ -0,0 +1,155 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg
+
+import (
+	"context"
+	"errors"
+	"iter"
+)
+
+// Map calls fn concurrently for each of the given inputs and returns the
+// results in the same order as the inputs. It is shorthand for creating a
+// [Job] for the given pool, launching a task for each input with
+// [ScatterAll], and gathering the results with [Job.CloseAndGatherAll]. The
+// pool's limits therefore bound the number of concurrent calls to fn, and the
+// given options apply to each task.
+//
+// Map returns the first error returned by fn or due to cancellation of the
+// given context, in which case the job is canceled and any remaining calls to
+// fn are abandoned; Map waits for those already running to return.
+//
+// The pool must not be bound to a job when Map is called. It is bound to the
+// job created by Map and unbound again before Map returns, so the same pool
+// may be used for successive calls but not for concurrent ones. To share a
+// limit among concurrent calls, give each its own pool and make them members
+// of a [SharedPool].
+func Map[I, R any](
+	ctx context.Context,
+	pool *Pool,
+	inputs []I,
+	fn func(context.Context, I) (R, error),
+	opts ...ScatterOption,
+) ([]R, error) {
+	job := NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	results := make([]R, len(inputs))
+	newTask := func(i int) TaskFunc[R] {
+		return func(ctx context.Context) (R, error) {
+			return fn(ctx, inputs[i])
+		}
+	}
+	newGather := func(i int) GatherFunc[R] {
+		return func(ctx context.Context, result R, err error) error {
+			if err != nil {
+				return err
+			}
+			results[i] = result
+			return nil
+		}
+	}
+	if err := ScatterSeq(ctx, pool, indices(len(inputs)), newTask, newGather, opts...); err != nil {
+		return nil, err
+	}
+	if err := job.CloseAndGatherAll(ctx); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ForEach calls fn concurrently for each of the given inputs like [Map], for
+// when there are no results to return.
+func ForEach[I any](
+	ctx context.Context,
+	pool *Pool,
+	inputs []I,
+	fn func(context.Context, I) error,
+	opts ...ScatterOption,
+) error {
+	job := NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	newTask := func(input I) TaskFunc[struct{}] {
+		return func(ctx context.Context) (struct{}, error) {
+			return struct{}{}, fn(ctx, input)
+		}
+	}
+	newGather := func(I) GatherFunc[struct{}] {
+		return func(ctx context.Context, _ struct{}, err error) error {
+			return err
+		}
+	}
+	if err := ScatterAll(ctx, pool, inputs, newTask, newGather, opts...); err != nil {
+		return err
+	}
+	return job.CloseAndGatherAll(ctx)
+}
+
+// MapUnordered calls fn concurrently for each of the given inputs like [Map],
+// but returns an iterator that yields the results in the order in which they
+// are gathered rather than in the order of the inputs. Nothing is launched
+// until iteration begins, and results are yielded while further calls to fn
+// are still being launched, so a slow loop body applies backpressure to the
+// launches.
+//
+// If an error occurs, the iterator yields it along with the zero value of R
+// and then ends. Exiting the loop early, or the first error, cancels the job
+// and abandons any remaining calls to fn; the iterator waits for those already
+// running to return. See Map for the requirements on the pool, which is bound
+// to a job only while iteration is in progress.
+func MapUnordered[I, R any](
+	ctx context.Context,
+	pool *Pool,
+	inputs []I,
+	fn func(context.Context, I) (R, error),
+	opts ...ScatterOption,
+) iter.Seq2[R, error] {
+	return func(yield func(R, error) bool) {
+		job := NewJob(ctx, pool)
+		defer job.CancelAndWait()
+
+		// Results are yielded from the gather function, which always runs
+		// within the calls below on the iterating goroutine.
+		newTask := func(input I) TaskFunc[R] {
+			return func(ctx context.Context) (R, error) {
+				return fn(ctx, input)
+			}
+		}
+		newGather := func(I) GatherFunc[R] {
+			return func(ctx context.Context, result R, err error) error {
+				if err != nil {
+					return err
+				}
+				if !yield(result, nil) {
+					return errStopIteration
+				}
+				return nil
+			}
+		}
+		err := ScatterAll(ctx, pool, inputs, newTask, newGather, opts...)
+		if err == nil {
+			err = job.CloseAndGatherAll(ctx)
+		}
+		if err != nil && !errors.Is(err, errStopIteration) {
+			var zero R
+			yield(zero, err)
+		}
+	}
+}
+
+// Returned by a gather function to stop gathering once the loop over an
+// iterator has been exited.
+var errStopIteration = errors.New("iteration stopped")
+
+// Returns a sequence of the integers from 0 to n-1.
+func indices(n int) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for i := range n {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/petenewcomb/psg-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMap(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	const limit = 4
+	pool := psg.NewPool(limit)
+
+	inputs := make([]int, 100)
+	for i := range inputs {
+		inputs[i] = i
+	}
+
+	var running, maxRunning atomic.Int64
+	square := func(ctx context.Context, input int) (int, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		// Finish out of order.
+		time.Sleep(time.Duration(input%3) * time.Millisecond)
+		return input * input, nil
+	}
+
+	results, err := psg.Map(ctx, pool, inputs, square)
+	chk.NoError(err)
+	chk.Len(results, len(inputs))
+	for i, result := range results {
+		chk.Equal(i*i, result)
+	}
+	chk.LessOrEqual(maxRunning.Load(), int64(limit))
+
+	// The pool may be used again once Map returns.
+	results, err = psg.Map(ctx, pool, []int{3}, square)
+	chk.NoError(err)
+	chk.Equal([]int{9}, results)
+}
+
+func TestMapError(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(2)
+
+	taskErr := errors.New("task failed")
+	results, err := psg.Map(ctx, pool, []int{1, 2, 3, 4, 5, 6, 7, 8, 9},
+		func(ctx context.Context, input int) (int, error) {
+			if input == 3 {
+				return 0, taskErr
+			}
+			return input, nil
+		},
+	)
+	chk.ErrorIs(err, taskErr)
+	chk.Nil(results)
+}
+
+func TestMapRepeatedErrors(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	const limit = 4
+	pool := psg.NewPool(limit)
+
+	// Each failing call abandons tasks that are still pending, which must
+	// release their capacity so that the pool remains usable.
+	taskErr := errors.New("task failed")
+	for range 20 {
+		_, err := psg.Map(ctx, pool, make([]int, 10),
+			func(ctx context.Context, input int) (int, error) {
+				return 0, taskErr
+			},
+		)
+		chk.ErrorIs(err, taskErr)
+		chk.Zero(pool.Stats().Weight)
+
+		err = psg.ForEach(ctx, pool, make([]int, 10),
+			func(ctx context.Context, input int) error {
+				return taskErr
+			},
+		)
+		chk.ErrorIs(err, taskErr)
+		chk.Zero(pool.Stats().Weight)
+	}
+
+	results, err := psg.Map(ctx, pool, []int{1, 2, 3, 4, 5, 6, 7, 8},
+		func(ctx context.Context, input int) (int, error) {
+			return input * 2, nil
+		},
+	)
+	chk.NoError(err)
+	chk.Equal([]int{2, 4, 6, 8, 10, 12, 14, 16}, results)
+}
+
+func TestMapCanceled(t *testing.T) {
+	chk := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := psg.NewPool(1)
+
+	results, err := psg.Map(ctx, pool, []int{1, 2, 3},
+		func(ctx context.Context, input int) (int, error) {
+			cancel()
+			<-ctx.Done()
+			return 0, ctx.Err()
+		},
+	)
+	chk.ErrorIs(err, context.Canceled)
+	chk.Nil(results)
+}
+
+func TestMapPoolAlreadyBoundPanic(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(1)
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	chk.PanicsWithValue("pool was already registered", func() {
+		_, _ = psg.Map(ctx, pool, []int{1},
+			func(ctx context.Context, input int) (int, error) {
+				return input, nil
+			},
+		)
+	})
+}
+
+func TestForEach(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(3)
+
+	var sum atomic.Int64
+	err := psg.ForEach(ctx, pool, []int{1, 2, 3, 4, 5},
+		func(ctx context.Context, input int) error {
+			sum.Add(int64(input))
+			return nil
+		},
+	)
+	chk.NoError(err)
+	chk.Equal(int64(15), sum.Load())
+
+	taskErr := errors.New("task failed")
+	err = psg.ForEach(ctx, pool, []int{1, 2, 3, 4, 5},
+		func(ctx context.Context, input int) error {
+			if input == 2 {
+				return taskErr
+			}
+			return nil
+		},
+	)
+	chk.ErrorIs(err, taskErr)
+}
+
+func TestMapUnordered(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(3)
+
+	inputs := []int{1, 2, 3, 4, 5, 6, 7, 8}
+	seq := psg.MapUnordered(ctx, pool, inputs,
+		func(ctx context.Context, input int) (int, error) {
+			return input * 10, nil
+		},
+	)
+
+	// Nothing is launched until iteration begins, so the pool is still free.
+	chk.NotPanics(func() {
+		psg.NewJob(ctx, pool).CancelAndWait()
+	})
+
+	var results []int
+	for result, err := range seq {
+		chk.NoError(err)
+		results = append(results, result)
+	}
+	slices.Sort(results)
+	chk.Equal([]int{10, 20, 30, 40, 50, 60, 70, 80}, results)
+	chk.NotPanics(func() {
+		psg.NewJob(ctx, pool).CancelAndWait()
+	})
+}
+
+func TestMapUnorderedError(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(1)
+
+	taskErr := errors.New("task failed")
+	var results []int
+	var errs []error
+	for result, err := range psg.MapUnordered(ctx, pool, []int{1, 2, 3, 4, 5},
+		func(ctx context.Context, input int) (int, error) {
+			if input == 3 {
+				return 0, taskErr
+			}
+			return input, nil
+		},
+	) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		results = append(results, result)
+	}
+	chk.Equal([]int{1, 2}, results)
+	chk.Len(errs, 1)
+	chk.ErrorIs(errs[0], taskErr)
+}
+
+func TestMapUnorderedBreak(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(2)
+
+	var calls atomic.Int64
+	count := 0
+	for _, err := range psg.MapUnordered(ctx, pool, make([]int, 100),
+		func(ctx context.Context, input int) (int, error) {
+			calls.Add(1)
+			return input, nil
+		},
+	) {
+		chk.NoError(err)
+		count++
+		if count == 3 {
+			break
+		}
+	}
+	chk.Equal(3, count)
+	chk.Less(calls.Load(), int64(100))
+	chk.NotPanics(func() {
+		psg.NewJob(ctx, pool).CancelAndWait()
+	})
+}
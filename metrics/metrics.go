@@ -0,0 +1,229 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+// Package metrics reports metrics about the pools and jobs of [psg] in the form
+// of a [prometheus.Collector].
+//
+// A [Collector] observes tasks as a [psg.JobObserver] and exposes the
+// following metrics, each labeled with the name of the pool (see
+// [psg.WithPoolName]) except where noted:
+//
+//   - psg_pool_limit: the current limit of the pool (see [psg.Pool.SetLimit])
+//   - psg_pool_tasks_in_flight: the number of tasks currently running
+//   - psg_tasks_launched_total: the number of tasks launched
+//   - psg_tasks_completed_total: the number of tasks that finished running,
+//     whether or not they failed
+//   - psg_tasks_failed_total: the number of tasks that finished with an error
+//   - psg_task_duration_seconds: a histogram of the time tasks spent running,
+//     including retries
+//   - psg_scatter_queue_wait_seconds: a histogram of the time [psg.Scatter]
+//     spent waiting for capacity before launching each task
+//   - psg_gather_duration_seconds: a histogram of the time spent in gather
+//     functions
+//   - psg_job_ungathered_results: the number of finished tasks whose results
+//     are waiting to be gathered, across all observed jobs (not labeled)
+//
+// The "psg" prefix may be changed with [WithNamespace]. Pools that share a name
+// share the same metrics, in which case psg_pool_limit reports the limit of
+// the one observed most recently.
+package metrics
+
+import (
+	"context"
+	"sync"
+
+	"github.com/petenewcomb/psg-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolLabel is the name of the label that identifies the pool to which a
+// metric applies.
+const PoolLabel = "pool"
+
+// A Collector is both a [psg.JobObserver] and a [prometheus.Collector]. Attach
+// it to jobs with [psg.WithJobObserver] or to individual pools with
+// [psg.WithPoolObserver], and register it with a [prometheus.Registerer]. A
+// single Collector may observe any number of jobs and pools concurrently.
+type Collector struct {
+	limit          *prometheus.Desc
+	inFlight       *prometheus.GaugeVec
+	launched       *prometheus.CounterVec
+	completed      *prometheus.CounterVec
+	failed         *prometheus.CounterVec
+	taskDuration   *prometheus.HistogramVec
+	queueWait      *prometheus.HistogramVec
+	gatherDuration *prometheus.HistogramVec
+	ungathered     prometheus.Gauge
+
+	// The pool most recently observed under each name, for reporting limits.
+	mu    sync.Mutex
+	pools map[string]*psg.Pool
+}
+
+var (
+	_ psg.JobObserver      = (*Collector)(nil)
+	_ prometheus.Collector = (*Collector)(nil)
+)
+
+type config struct {
+	namespace string
+	buckets   []float64
+}
+
+// An Option configures a [Collector]. See [NewCollector].
+type Option func(*config)
+
+// WithNamespace returns an [Option] that replaces the "psg" prefix of the
+// names of all metrics.
+func WithNamespace(namespace string) Option {
+	return func(cfg *config) {
+		cfg.namespace = namespace
+	}
+}
+
+// WithBuckets returns an [Option] that sets the upper bounds, in seconds, of
+// the buckets of all histograms. The default is [prometheus.DefBuckets].
+func WithBuckets(buckets ...float64) Option {
+	return func(cfg *config) {
+		cfg.buckets = buckets
+	}
+}
+
+// NewCollector creates a [Collector] with the given options.
+func NewCollector(opts ...Option) *Collector {
+	cfg := config{
+		namespace: "psg",
+		buckets:   prometheus.DefBuckets,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	labels := []string{PoolLabel}
+	histogram := func(name, help string) *prometheus.HistogramVec {
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: cfg.namespace,
+			Name:      name,
+			Help:      help,
+			Buckets:   cfg.buckets,
+		}, labels)
+	}
+	counter := func(name, help string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.namespace,
+			Name:      name,
+			Help:      help,
+		}, labels)
+	}
+	return &Collector{
+		limit: prometheus.NewDesc(
+			prometheus.BuildFQName(cfg.namespace, "pool", "limit"),
+			"Current concurrency limit of the pool.",
+			labels, nil,
+		),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: cfg.namespace,
+			Subsystem: "pool",
+			Name:      "tasks_in_flight",
+			Help:      "Number of tasks currently running in the pool.",
+		}, labels),
+		launched:       counter("tasks_launched_total", "Number of tasks launched."),
+		completed:      counter("tasks_completed_total", "Number of tasks that finished running."),
+		failed:         counter("tasks_failed_total", "Number of tasks that finished with an error."),
+		taskDuration:   histogram("task_duration_seconds", "Time tasks spent running, including retries."),
+		queueWait:      histogram("scatter_queue_wait_seconds", "Time spent waiting for pool capacity before launching tasks."),
+		gatherDuration: histogram("gather_duration_seconds", "Time spent in gather functions."),
+		ungathered: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: cfg.namespace,
+			Subsystem: "job",
+			Name:      "ungathered_results",
+			Help:      "Number of finished tasks whose results are waiting to be gathered.",
+		}),
+		pools: make(map[string]*psg.Pool),
+	}
+}
+
+// Describe implements [prometheus.Collector].
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.limit
+	c.inFlight.Describe(ch)
+	c.launched.Describe(ch)
+	c.completed.Describe(ch)
+	c.failed.Describe(ch)
+	c.taskDuration.Describe(ch)
+	c.queueWait.Describe(ch)
+	c.gatherDuration.Describe(ch)
+	c.ungathered.Describe(ch)
+}
+
+// Collect implements [prometheus.Collector].
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	for name, p := range c.pools {
+		ch <- prometheus.MustNewConstMetric(c.limit, prometheus.GaugeValue, float64(p.Limit()), name)
+	}
+	c.mu.Unlock()
+	c.inFlight.Collect(ch)
+	c.launched.Collect(ch)
+	c.completed.Collect(ch)
+	c.failed.Collect(ch)
+	c.taskDuration.Collect(ch)
+	c.queueWait.Collect(ch)
+	c.gatherDuration.Collect(ch)
+	c.ungathered.Collect(ch)
+}
+
+// Remembers the given pool for reporting its limit.
+func (c *Collector) addPool(p *psg.Pool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pools[p.Name()] = p
+}
+
+// ScatterBlocked implements [psg.PoolObserver].
+func (c *Collector) ScatterBlocked(ctx context.Context, ev psg.ScatterBlockedEvent) {
+	c.addPool(ev.Pool)
+}
+
+// TaskLaunched implements [psg.PoolObserver].
+func (c *Collector) TaskLaunched(ctx context.Context, ev psg.TaskLaunchedEvent) context.Context {
+	c.addPool(ev.Pool)
+	name := ev.Pool.Name()
+	c.launched.WithLabelValues(name).Inc()
+	c.inFlight.WithLabelValues(name).Inc()
+	c.queueWait.WithLabelValues(name).Observe(ev.QueueDelay.Seconds())
+	return ev.TaskContext
+}
+
+// TaskFinished implements [psg.PoolObserver].
+func (c *Collector) TaskFinished(ctx context.Context, ev psg.TaskFinishedEvent) {
+	name := ev.Pool.Name()
+	c.inFlight.WithLabelValues(name).Dec()
+	c.completed.WithLabelValues(name).Inc()
+	if ev.Err != nil {
+		c.failed.WithLabelValues(name).Inc()
+	}
+	c.taskDuration.WithLabelValues(name).Observe(ev.Duration.Seconds())
+	// A task that never started has no result to gather.
+	if ev.Attempts > 0 {
+		c.ungathered.Inc()
+	}
+}
+
+// GatherStarted implements [psg.PoolObserver].
+func (c *Collector) GatherStarted(ctx context.Context, ev psg.GatherStartedEvent) context.Context {
+	c.ungathered.Dec()
+	return ctx
+}
+
+// GatherFinished implements [psg.PoolObserver].
+func (c *Collector) GatherFinished(ctx context.Context, ev psg.GatherFinishedEvent) {
+	c.gatherDuration.WithLabelValues(ev.Pool.Name()).Observe(ev.Duration.Seconds())
+}
+
+// ResultDiscarded implements [psg.PoolObserver].
+func (c *Collector) ResultDiscarded(ctx context.Context, ev psg.ResultDiscardedEvent) {
+	c.ungathered.Dec()
+}
+
+// JobClosed implements [psg.JobObserver].
+func (c *Collector) JobClosed(ev psg.JobClosedEvent) {}
@@ -0,0 +1,88 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/petenewcomb/psg-go"
+	"github.com/petenewcomb/psg-go/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, url string) string {
+	t.Helper()
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestCollectorScrape(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	collector := metrics.NewCollector()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+	server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	defer server.Close()
+
+	pool := psg.NewPool(2, psg.WithPoolName("fetch"))
+	job := psg.NewJob(ctx, pool, psg.WithJobObserver(collector))
+	defer job.CancelAndWait()
+
+	errFailed := errors.New("failed")
+	release := make(chan struct{})
+	gather := func(ctx context.Context, result int, err error) error {
+		return nil
+	}
+	chk.NoError(psg.Scatter(ctx, pool,
+		func(ctx context.Context) (int, error) {
+			<-release
+			return 0, errFailed
+		},
+		gather,
+	))
+	chk.NoError(psg.Scatter(ctx, pool,
+		func(ctx context.Context) (int, error) {
+			return 0, nil
+		},
+		gather,
+	))
+	pool.SetLimit(3)
+
+	// Wait for the second task to finish without gathering it.
+	chk.Eventually(func() bool {
+		return strings.Contains(scrape(t, server.URL), "psg_job_ungathered_results 1\n")
+	}, time.Second, time.Millisecond)
+
+	body := scrape(t, server.URL)
+	chk.Contains(body, `psg_pool_limit{pool="fetch"} 3`+"\n")
+	chk.Contains(body, `psg_pool_tasks_in_flight{pool="fetch"} 1`+"\n")
+	chk.Contains(body, `psg_tasks_launched_total{pool="fetch"} 2`+"\n")
+	chk.Contains(body, `psg_tasks_completed_total{pool="fetch"} 1`+"\n")
+
+	close(release)
+	chk.NoError(job.CloseAndGatherAll(ctx))
+
+	body = scrape(t, server.URL)
+	chk.Contains(body, `psg_pool_tasks_in_flight{pool="fetch"} 0`+"\n")
+	chk.Contains(body, `psg_tasks_completed_total{pool="fetch"} 2`+"\n")
+	chk.Contains(body, `psg_tasks_failed_total{pool="fetch"} 1`+"\n")
+	chk.Contains(body, `psg_task_duration_seconds_count{pool="fetch"} 2`+"\n")
+	chk.Contains(body, `psg_scatter_queue_wait_seconds_count{pool="fetch"} 2`+"\n")
+	chk.Contains(body, `psg_gather_duration_seconds_count{pool="fetch"} 2`+"\n")
+	chk.Contains(body, "psg_job_ungathered_results 0\n")
+}
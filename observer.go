@@ -0,0 +1,303 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg
+
+import (
+	"context"
+	"time"
+)
+
+// A PoolObserver receives notifications of events in the life cycle of tasks
+// launched into a [Pool], for instance to record metrics or traces. Attach one
+// to a single pool with [WithPoolObserver], or to every pool of a job with
+// [WithJobObserver].
+//
+// Callbacks are made synchronously from the goroutines involved in each event,
+// often concurrently, so implementations must be thread-safe and should return
+// quickly to avoid delaying the job.
+//
+// Some callbacks return a context. These allow an observer to attach values,
+// such as a tracing span, to the context passed to a [TaskFunc] or
+// [GatherFunc]. An observer with nothing to add simply returns the context it
+// would otherwise replace.
+type PoolObserver interface {
+	// ScatterBlocked is called when a call to [Scatter] must wait for capacity
+	// in the pool before launching its task. The context is the one passed to
+	// Scatter. It is called at most once per call to Scatter, and is followed
+	// by TaskLaunched unless Scatter fails.
+	ScatterBlocked(ctx context.Context, ev ScatterBlockedEvent)
+
+	// TaskLaunched is called from within [Scatter] or [TryScatter] when a task
+	// is launched. The context is the one passed to Scatter or TryScatter. The
+	// returned context is passed to the task function, and must be
+	// ev.TaskContext or derived from it.
+	TaskLaunched(ctx context.Context, ev TaskLaunchedEvent) context.Context
+
+	// TaskFinished is called from the task's goroutine when the task has
+	// finished running, including all retries. The context is the one that was
+	// passed to the task function.
+	TaskFinished(ctx context.Context, ev TaskFinishedEvent)
+
+	// GatherStarted is called before the task's [GatherFunc] is called. The
+	// context is the one about to be passed to the GatherFunc, which is based
+	// on the one passed to the gathering method. The returned context is
+	// passed to the GatherFunc instead, and must be ctx or derived from it.
+	GatherStarted(ctx context.Context, ev GatherStartedEvent) context.Context
+
+	// GatherFinished is called after the task's [GatherFunc] returns. The
+	// context is the one that was passed to the GatherFunc.
+	GatherFinished(ctx context.Context, ev GatherFinishedEvent)
+
+	// ResultDiscarded is called from the task's goroutine instead of
+	// GatherStarted and GatherFinished if the job is canceled after the task
+	// finished but before its result was gathered. For a result held in the
+	// job's buffer (see WithResultBuffer), it is instead called from the
+	// goroutine that discards the buffer. The context is the one that was
+	// passed to the task function.
+	ResultDiscarded(ctx context.Context, ev ResultDiscardedEvent)
+}
+
+// A JobObserver receives the notifications of a [PoolObserver] for every pool
+// bound to a [Job], as well as notifications about the job itself. See
+// [WithJobObserver].
+type JobObserver interface {
+	PoolObserver
+
+	// JobClosed is called the first time [Job.Close] is called.
+	JobClosed(ev JobClosedEvent)
+}
+
+// ScatterBlockedEvent describes a call to [Scatter] that is waiting for
+// capacity. See [PoolObserver].
+type ScatterBlockedEvent struct {
+	// Pool is the pool into which the task is being scattered.
+	Pool *Pool
+	// Time is when Scatter started waiting.
+	Time time.Time
+	// Weight and Priority are the task's weight and priority as set by
+	// [WithWeight] and [WithPriority].
+	Weight, Priority int
+}
+
+// TaskLaunchedEvent describes the launch of a task. See [PoolObserver].
+type TaskLaunchedEvent struct {
+	// Pool is the pool into which the task was launched.
+	Pool *Pool
+	// TaskContext is the context that will be passed to the task function
+	// unless replaced by an observer.
+	TaskContext context.Context
+	// ScatterTime is when [Scatter] or [TryScatter] was called.
+	ScatterTime time.Time
+	// Time is when the task was launched.
+	Time time.Time
+	// QueueDelay is how long Scatter spent waiting for capacity before
+	// launching the task, or zero if it did not have to wait.
+	QueueDelay time.Duration
+	// Weight and Priority are the task's weight and priority as set by
+	// [WithWeight] and [WithPriority].
+	Weight, Priority int
+}
+
+// TaskFinishedEvent describes the completion of a task. See [PoolObserver].
+type TaskFinishedEvent struct {
+	// Pool is the pool into which the task was launched.
+	Pool *Pool
+	// LaunchTime is when the task was launched.
+	LaunchTime time.Time
+	// Time is when the task finished.
+	Time time.Time
+	// Duration is how long the task ran, including any retries.
+	Duration time.Duration
+	// Attempts is the number of times the task function was called, which is
+	// zero if the job was canceled before the task could start.
+	Attempts int
+	// Err is the error that will be passed to the task's [GatherFunc], or the
+	// cause of the job's cancellation if the task did not start.
+	Err error
+}
+
+// GatherStartedEvent describes the start of a call to a task's [GatherFunc].
+// See [PoolObserver].
+type GatherStartedEvent struct {
+	// Pool is the pool into which the task was launched.
+	Pool *Pool
+	// TaskContext is the context that was passed to the task function.
+	TaskContext context.Context
+	// FinishTime is when the task finished.
+	FinishTime time.Time
+	// Time is when gathering started.
+	Time time.Time
+	// Wait is how long the task's result waited to be gathered.
+	Wait time.Duration
+}
+
+// GatherFinishedEvent describes the return of a task's [GatherFunc]. See
+// [PoolObserver].
+type GatherFinishedEvent struct {
+	// Pool is the pool into which the task was launched.
+	Pool *Pool
+	// StartTime is when gathering started.
+	StartTime time.Time
+	// Time is when gathering finished.
+	Time time.Time
+	// Duration is how long the GatherFunc ran.
+	Duration time.Duration
+	// Err is the error returned by the GatherFunc.
+	Err error
+}
+
+// ResultDiscardedEvent describes the result of a task that was discarded
+// without being gathered. See [PoolObserver].
+type ResultDiscardedEvent struct {
+	// Pool is the pool into which the task was launched.
+	Pool *Pool
+	// FinishTime is when the task finished.
+	FinishTime time.Time
+	// Time is when the result was discarded.
+	Time time.Time
+	// Err is the cause of the job's cancellation.
+	Err error
+}
+
+// JobClosedEvent describes the closing of a job. See [JobObserver].
+type JobClosedEvent struct {
+	// Job is the job that was closed.
+	Job *Job
+	// Time is when the job was closed.
+	Time time.Time
+}
+
+// WithPoolObserver returns a [PoolOption] that reports the events of tasks
+// launched into the pool to the given observer. If the pool's job also has
+// observers (see [WithJobObserver]), the pool's observers are notified first.
+func WithPoolObserver(observer PoolObserver) PoolOption {
+	return func(p *Pool) {
+		p.observers = append(p.observers, observer)
+	}
+}
+
+// WithJobObserver returns a [JobOption] that reports the events of tasks in
+// all of the job's pools, as well as those of the job itself, to the given
+// observer.
+func WithJobObserver(observer JobObserver) JobOption {
+	return jobOptionFunc(func(j *Job) {
+		j.observers = append(j.observers, observer)
+	})
+}
+
+// Combines the pool's own observers with those of its job. Called once all
+// of the job's options have been applied.
+func (p *Pool) bindObservers(j *Job) {
+	p.allObservers = make([]PoolObserver, 0, len(p.observers)+len(j.observers))
+	p.allObservers = append(p.allObservers, p.observers...)
+	for _, o := range j.observers {
+		p.allObservers = append(p.allObservers, o)
+	}
+}
+
+func (p *Pool) scatterBlocked(ctx context.Context, cfg *scatterConfig) {
+	ev := ScatterBlockedEvent{
+		Pool:     p,
+		Time:     time.Now(),
+		Weight:   cfg.weight,
+		Priority: cfg.priority,
+	}
+	for _, o := range p.allObservers {
+		o.ScatterBlocked(ctx, ev)
+	}
+}
+
+func (p *Pool) taskLaunched(ctx context.Context, cfg *scatterConfig, t *launchedTask, scatterTime time.Time, blocked bool) {
+	t.launchTime = time.Now()
+	ev := TaskLaunchedEvent{
+		Pool:        p,
+		ScatterTime: scatterTime,
+		Time:        t.launchTime,
+		Weight:      cfg.weight,
+		Priority:    cfg.priority,
+	}
+	if blocked {
+		ev.QueueDelay = t.launchTime.Sub(scatterTime)
+	}
+	for _, o := range t.observers {
+		ev.TaskContext = t.ctx
+		t.ctx = o.TaskLaunched(ctx, ev)
+	}
+}
+
+func (p *Pool) taskFinished(t *launchedTask, attempts int, err error) {
+	if len(t.observers) == 0 {
+		return
+	}
+	t.finishTime = time.Now()
+	ev := TaskFinishedEvent{
+		Pool:       p,
+		LaunchTime: t.launchTime,
+		Time:       t.finishTime,
+		Duration:   t.finishTime.Sub(t.launchTime),
+		Attempts:   attempts,
+		Err:        err,
+	}
+	for _, o := range t.observers {
+		o.TaskFinished(t.ctx, ev)
+	}
+}
+
+// Wraps the gather function to notify observers before and after it runs.
+func (p *Pool) observeGather(t *launchedTask, gather boundGatherFunc) boundGatherFunc {
+	if len(t.observers) == 0 {
+		return gather
+	}
+	return func(ctx context.Context) error {
+		start := time.Now()
+		ev := GatherStartedEvent{
+			Pool:        p,
+			TaskContext: t.ctx,
+			FinishTime:  t.finishTime,
+			Time:        start,
+			Wait:        start.Sub(t.finishTime),
+		}
+		for _, o := range t.observers {
+			ctx = o.GatherStarted(ctx, ev)
+		}
+		err := gather(ctx)
+		end := time.Now()
+		finished := GatherFinishedEvent{
+			Pool:      p,
+			StartTime: start,
+			Time:      end,
+			Duration:  end.Sub(start),
+			Err:       err,
+		}
+		for _, o := range t.observers {
+			o.GatherFinished(ctx, finished)
+		}
+		return err
+	}
+}
+
+func (p *Pool) resultDiscarded(t *launchedTask) {
+	if len(t.observers) == 0 {
+		return
+	}
+	ev := ResultDiscardedEvent{
+		Pool:       p,
+		FinishTime: t.finishTime,
+		Time:       time.Now(),
+		Err:        context.Cause(t.ctx),
+	}
+	for _, o := range t.observers {
+		o.ResultDiscarded(t.ctx, ev)
+	}
+}
+
+func (j *Job) jobClosed() {
+	ev := JobClosedEvent{
+		Job:  j,
+		Time: time.Now(),
+	}
+	for _, o := range j.observers {
+		o.JobClosed(ev)
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/petenewcomb/psg-go"
+	"github.com/stretchr/testify/require"
+)
+
+type observerKeyType struct{}
+
+var observerKey any = observerKeyType{}
+
+// Records the events it observes as strings, and tags task and gather contexts
+// so that tests can check that they are passed through.
+type recordingObserver struct {
+	name   string
+	mu     sync.Mutex
+	events []string
+}
+
+func (o *recordingObserver) record(format string, args ...any) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, fmt.Sprintf(format, args...))
+}
+
+func (o *recordingObserver) ScatterBlocked(ctx context.Context, ev psg.ScatterBlockedEvent) {
+	o.record("%s blocked w%d", o.name, ev.Weight)
+}
+
+func (o *recordingObserver) TaskLaunched(ctx context.Context, ev psg.TaskLaunchedEvent) context.Context {
+	o.record("%s launched", o.name)
+	return context.WithValue(ev.TaskContext, observerKey, o.name)
+}
+
+func (o *recordingObserver) TaskFinished(ctx context.Context, ev psg.TaskFinishedEvent) {
+	o.record("%s finished %v %v", o.name, ctx.Value(observerKey), ev.Err)
+}
+
+func (o *recordingObserver) GatherStarted(ctx context.Context, ev psg.GatherStartedEvent) context.Context {
+	o.record("%s gathering %v", o.name, ev.TaskContext.Value(observerKey))
+	return context.WithValue(ctx, observerKey, o.name+" gather")
+}
+
+func (o *recordingObserver) GatherFinished(ctx context.Context, ev psg.GatherFinishedEvent) {
+	o.record("%s gathered %v %v", o.name, ctx.Value(observerKey), ev.Err)
+}
+
+func (o *recordingObserver) ResultDiscarded(ctx context.Context, ev psg.ResultDiscardedEvent) {
+	o.record("%s discarded %v %v", o.name, ctx.Value(observerKey), ev.Err)
+}
+
+func (o *recordingObserver) JobClosed(ev psg.JobClosedEvent) {
+	o.record("%s closed", o.name)
+}
+
+func TestObserverEvents(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	poolObserver := &recordingObserver{name: "pool"}
+	jobObserver := &recordingObserver{name: "job"}
+	pool := psg.NewPool(2, psg.WithPoolObserver(poolObserver))
+	job := psg.NewJob(ctx, pool, psg.WithJobObserver(jobObserver))
+	defer job.CancelAndWait()
+
+	errFailed := errors.New("failed")
+	var taskValues, gatherValues []any
+	release := make(chan struct{})
+	newTask := func(id int) psg.TaskFunc[int] {
+		return func(ctx context.Context) (int, error) {
+			taskValues = append(taskValues, ctx.Value(observerKey))
+			<-release
+			return id, errFailed
+		}
+	}
+	gather := func(ctx context.Context, result int, err error) error {
+		gatherValues = append(gatherValues, ctx.Value(observerKey))
+		if result == 2 {
+			return err
+		}
+		return nil
+	}
+
+	chk.NoError(psg.Scatter(ctx, pool, newTask(1), gather))
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+	chk.NoError(psg.Scatter(ctx, pool, newTask(2), gather, psg.WithWeight(2)))
+	ok, err := job.GatherOne(ctx)
+	chk.True(ok)
+	chk.ErrorIs(err, errFailed)
+	chk.NoError(job.CloseAndGatherAll(ctx))
+
+	// The pool's observers are called before the job's, each seeing the
+	// context returned by the one before.
+	chk.Equal([]any{"job", "job"}, taskValues)
+	chk.Equal([]any{"job gather", "job gather"}, gatherValues)
+	chk.Equal([]string{
+		"pool launched",
+		"pool blocked w2",
+		"pool finished job failed",
+		"pool gathering job",
+		"pool gathered job gather <nil>",
+		"pool launched",
+		"pool finished job failed",
+		"pool gathering job",
+		"pool gathered job gather failed",
+	}, poolObserver.events)
+	chk.Equal([]string{
+		"job launched",
+		"job blocked w2",
+		"job finished job failed",
+		"job gathering job",
+		"job gathered job gather <nil>",
+		"job launched",
+		"job finished job failed",
+		"job gathering job",
+		"job gathered job gather failed",
+		"job closed",
+	}, jobObserver.events)
+}
+
+func TestObserverResultDiscarded(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	observer := &recordingObserver{name: "job"}
+	pool := psg.NewPool(-1)
+	job := psg.NewJob(ctx, pool, psg.WithJobObserver(observer))
+
+	chk.NoError(psg.Scatter(ctx, pool,
+		func(ctx context.Context) (int, error) {
+			return 0, nil
+		},
+		func(ctx context.Context, result int, err error) error {
+			return err
+		},
+	))
+
+	// Wait for the task to finish before canceling the job.
+	chk.Eventually(func() bool {
+		return job.Stats().Ready == 1
+	}, time.Second, time.Millisecond)
+	job.CancelAndWait()
+	chk.Equal([]string{
+		"job launched",
+		"job finished job <nil>",
+		"job discarded job context canceled",
+	}, observer.events)
+}
+
+func TestObserverBufferedResultDiscarded(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	observer := &recordingObserver{name: "job"}
+	pool := psg.NewPool(-1)
+	job := psg.NewJob(ctx, pool, psg.WithJobObserver(observer), psg.WithResultBuffer(1))
+
+	chk.NoError(psg.Scatter(ctx, pool,
+		func(ctx context.Context) (int, error) {
+			return 0, nil
+		},
+		func(ctx context.Context, result int, err error) error {
+			return err
+		},
+	))
+
+	// Wait for the result to be buffered before canceling the job.
+	chk.Eventually(func() bool {
+		return job.Stats().Ready == 1
+	}, time.Second, time.Millisecond)
+	job.CancelAndWait()
+	chk.Equal([]string{
+		"job launched",
+		"job finished job <nil>",
+		"job discarded job context canceled",
+	}, observer.events)
+}
+
+type timingObserver struct {
+	recordingObserver
+	launched chan psg.TaskLaunchedEvent
+	gathered chan psg.GatherStartedEvent
+}
+
+func (o *timingObserver) TaskLaunched(ctx context.Context, ev psg.TaskLaunchedEvent) context.Context {
+	o.launched <- ev
+	return ev.TaskContext
+}
+
+func (o *timingObserver) GatherStarted(ctx context.Context, ev psg.GatherStartedEvent) context.Context {
+	o.gathered <- ev
+	return ctx
+}
+
+func TestObserverTiming(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	observer := &timingObserver{
+		launched: make(chan psg.TaskLaunchedEvent, 2),
+		gathered: make(chan psg.GatherStartedEvent, 2),
+	}
+	pool := psg.NewPool(1)
+	job := psg.NewJob(ctx, pool, psg.WithJobObserver(observer))
+	defer job.CancelAndWait()
+
+	release := make(chan struct{})
+	task := func(ctx context.Context) (int, error) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return 0, nil
+	}
+	gather := func(ctx context.Context, result int, err error) error {
+		return err
+	}
+	chk.NoError(psg.Scatter(ctx, pool, task, gather))
+	chk.Zero((<-observer.launched).QueueDelay)
+
+	// The second task must wait for the first to finish, so its queue delay
+	// spans the time it is seen to be blocked.
+	scatterErr := make(chan error, 1)
+	go func() {
+		scatterErr <- psg.Scatter(ctx, pool, task, gather)
+	}()
+	chk.Eventually(func() bool {
+		return pool.Stats().Blocked == 1
+	}, time.Second, time.Millisecond)
+	blocked := time.Now()
+	close(release)
+	chk.NoError(<-scatterErr)
+	ev := <-observer.launched
+	chk.Equal(ev.Pool, pool)
+	chk.False(ev.ScatterTime.After(blocked))
+	chk.True(ev.Time.After(blocked))
+	chk.Equal(ev.Time.Sub(ev.ScatterTime), ev.QueueDelay)
+
+	// Each result's wait spans the time from when it finished, which is no
+	// later than when both tasks are seen to have finished.
+	chk.Eventually(func() bool {
+		return job.Stats().InFlight == 0
+	}, time.Second, time.Millisecond)
+	finished := time.Now()
+	chk.NoError(job.CloseAndGatherAll(ctx))
+	var gev psg.GatherStartedEvent
+	for range 2 {
+		gev = <-observer.gathered
+		chk.False(gev.FinishTime.After(finished))
+		chk.Equal(gev.Time.Sub(gev.FinishTime), gev.Wait)
+	}
+	// The blocked Scatter may have gathered the first task's result, but the
+	// second's is not gathered until the job is closed.
+	chk.True(gev.Time.After(finished))
+}
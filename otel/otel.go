@@ -0,0 +1,155 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+// Package otel traces the tasks and gathers of [psg] jobs with [OpenTelemetry].
+//
+// An [Observer] creates a span for each task, covering all of its attempts,
+// and a span for each call to a task's gather function. Task spans are
+// children of the span active in the context passed to [psg.Scatter], and
+// the span of each task is made active in the context passed to its task
+// function. Likewise, gather spans are children of the span active in the
+// context passed to the gathering method and are made active in the context
+// passed to the gather function. Each gather span links to the span of the
+// task whose result it gathers, and each task scattered from within a gather
+// function links to the span of the task whose gather scattered it. Trace
+// context therefore follows a pipeline from one stage to the next, even though
+// psg runs each stage in a separate goroutine.
+//
+// [OpenTelemetry]: https://opentelemetry.io
+package otel
+
+import (
+	"context"
+	"time"
+
+	"github.com/petenewcomb/psg-go"
+	otelapi "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope name used to obtain a tracer.
+const ScopeName = "github.com/petenewcomb/psg-go/otel"
+
+// Attribute keys used on the spans created by an [Observer].
+const (
+	PoolKey       = attribute.Key("psg.pool.name")
+	WeightKey     = attribute.Key("psg.task.weight")
+	PriorityKey   = attribute.Key("psg.task.priority")
+	QueueDelayKey = attribute.Key("psg.task.queue_delay_ms")
+	AttemptsKey   = attribute.Key("psg.task.attempts")
+	WaitKey       = attribute.Key("psg.gather.wait_ms")
+)
+
+// An Observer is a [psg.JobObserver] that creates spans for tasks and gathers.
+// Attach it to a job with [psg.WithJobObserver], or to individual pools with
+// [psg.WithPoolObserver]. See the package documentation for the shape of the
+// resulting traces.
+type Observer struct {
+	tracer trace.Tracer
+}
+
+var _ psg.JobObserver = (*Observer)(nil)
+
+// An Option configures an [Observer]. See [NewObserver].
+type Option func(*Observer)
+
+// WithTracerProvider returns an [Option] that makes the observer obtain its
+// tracer from the given provider rather than the global one.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(o *Observer) {
+		o.tracer = provider.Tracer(ScopeName)
+	}
+}
+
+// NewObserver creates an [Observer] with the given options.
+func NewObserver(opts ...Option) *Observer {
+	o := &Observer{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.tracer == nil {
+		o.tracer = otelapi.GetTracerProvider().Tracer(ScopeName)
+	}
+	return o
+}
+
+type gatheredTaskKeyType struct{}
+
+var gatheredTaskKey any = gatheredTaskKeyType{}
+
+// ScatterBlocked adds an event to the span active in the scattering context.
+func (o *Observer) ScatterBlocked(ctx context.Context, ev psg.ScatterBlockedEvent) {
+	trace.SpanFromContext(ctx).AddEvent("psg.scatter.blocked",
+		trace.WithTimestamp(ev.Time),
+		trace.WithAttributes(
+			PoolKey.String(ev.Pool.Name()),
+			WeightKey.Int(ev.Weight),
+			PriorityKey.Int(ev.Priority),
+		),
+	)
+}
+
+// TaskLaunched starts the task's span.
+func (o *Observer) TaskLaunched(ctx context.Context, ev psg.TaskLaunchedEvent) context.Context {
+	opts := []trace.SpanStartOption{
+		trace.WithTimestamp(ev.Time),
+		trace.WithAttributes(
+			PoolKey.String(ev.Pool.Name()),
+			WeightKey.Int(ev.Weight),
+			PriorityKey.Int(ev.Priority),
+			QueueDelayKey.Float64(milliseconds(ev.QueueDelay)),
+		),
+	}
+	// Link back to the task whose gather function scattered this one.
+	if sc, ok := ctx.Value(gatheredTaskKey).(trace.SpanContext); ok {
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: sc}))
+	}
+	_, span := o.tracer.Start(ctx, "psg.task", opts...)
+	return trace.ContextWithSpan(ev.TaskContext, span)
+}
+
+// TaskFinished ends the task's span.
+func (o *Observer) TaskFinished(ctx context.Context, ev psg.TaskFinishedEvent) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(AttemptsKey.Int(ev.Attempts))
+	endSpan(span, ev.Err, ev.Time)
+}
+
+// GatherStarted starts the gather's span.
+func (o *Observer) GatherStarted(ctx context.Context, ev psg.GatherStartedEvent) context.Context {
+	task := trace.SpanContextFromContext(ev.TaskContext)
+	ctx, _ = o.tracer.Start(ctx, "psg.gather",
+		trace.WithTimestamp(ev.Time),
+		trace.WithLinks(trace.Link{SpanContext: task}),
+		trace.WithAttributes(
+			PoolKey.String(ev.Pool.Name()),
+			WaitKey.Float64(milliseconds(ev.Wait)),
+		),
+	)
+	return context.WithValue(ctx, gatheredTaskKey, task)
+}
+
+// GatherFinished ends the gather's span.
+func (o *Observer) GatherFinished(ctx context.Context, ev psg.GatherFinishedEvent) {
+	endSpan(trace.SpanFromContext(ctx), ev.Err, ev.Time)
+}
+
+// ResultDiscarded does nothing, since the task's span has already ended.
+func (o *Observer) ResultDiscarded(ctx context.Context, ev psg.ResultDiscardedEvent) {}
+
+// JobClosed does nothing.
+func (o *Observer) JobClosed(ev psg.JobClosedEvent) {}
+
+func endSpan(span trace.Span, err error, t time.Time) {
+	if err != nil {
+		span.RecordError(err, trace.WithTimestamp(t))
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(t))
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
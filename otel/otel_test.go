@@ -0,0 +1,115 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package otel_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/petenewcomb/psg-go"
+	psgotel "github.com/petenewcomb/psg-go/otel"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestPipelineSpans(t *testing.T) {
+	chk := require.New(t)
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	tracer := provider.Tracer("test")
+
+	ctx, root := tracer.Start(context.Background(), "root")
+	pool := psg.NewPool(-1)
+	job := psg.NewJob(ctx, pool,
+		psg.WithJobObserver(psgotel.NewObserver(psgotel.WithTracerProvider(provider))),
+	)
+	defer job.CancelAndWait()
+
+	// The first stage starts a span of its own and then hands off to a second
+	// stage, which fails.
+	errFailed := errors.New("failed")
+	second := func(ctx context.Context) (int, error) {
+		return 0, errFailed
+	}
+	first := func(ctx context.Context) (int, error) {
+		_, span := tracer.Start(ctx, "work")
+		span.End()
+		return 0, nil
+	}
+	chk.NoError(psg.Scatter(ctx, pool, first,
+		func(ctx context.Context, result int, err error) error {
+			return psg.Scatter(ctx, pool, second,
+				func(ctx context.Context, result int, err error) error {
+					return err
+				},
+			)
+		},
+	))
+	chk.ErrorIs(job.CloseAndGatherAll(ctx), errFailed)
+	root.End()
+	job.CancelAndWait()
+
+	byName := map[string]tracetest.SpanStub{}
+	var tasks []tracetest.SpanStub
+	var gathers []tracetest.SpanStub
+	for _, s := range exporter.GetSpans() {
+		switch s.Name {
+		case "psg.task":
+			tasks = append(tasks, s)
+		case "psg.gather":
+			gathers = append(gathers, s)
+		default:
+			byName[s.Name] = s
+		}
+	}
+	chk.Len(tasks, 2)
+	chk.Len(gathers, 2)
+	firstTask, secondTask := tasks[0], tasks[1]
+	firstGather, secondGather := gathers[0], gathers[1]
+
+	spanID := func(s tracetest.SpanStub) trace.SpanID {
+		return s.SpanContext.SpanID()
+	}
+	links := func(s tracetest.SpanStub) []trace.SpanID {
+		var ids []trace.SpanID
+		for _, l := range s.Links {
+			ids = append(ids, l.SpanContext.SpanID())
+		}
+		return ids
+	}
+
+	// All spans belong to the same trace.
+	for _, s := range exporter.GetSpans() {
+		chk.Equal(root.SpanContext().TraceID(), s.SpanContext.TraceID())
+	}
+
+	// The first task is a child of the scattering context, and its span is
+	// active within the task function.
+	chk.Equal(root.SpanContext().SpanID(), firstTask.Parent.SpanID())
+	chk.Empty(firstTask.Links)
+	chk.Equal(spanID(firstTask), byName["work"].Parent.SpanID())
+	chk.Equal(codes.Unset, firstTask.Status.Code)
+
+	// The first gather is a child of the gathering context and links to its
+	// task.
+	chk.Equal(root.SpanContext().SpanID(), firstGather.Parent.SpanID())
+	chk.Equal([]trace.SpanID{spanID(firstTask)}, links(firstGather))
+	chk.Equal(codes.Unset, firstGather.Status.Code)
+
+	// The second task is a child of the gather that scattered it and links
+	// back to the first task.
+	chk.Equal(spanID(firstGather), secondTask.Parent.SpanID())
+	chk.Equal([]trace.SpanID{spanID(firstTask)}, links(secondTask))
+	chk.Equal(codes.Error, secondTask.Status.Code)
+
+	// The second gather is also a child of the gathering context, links to
+	// its task, and reports the error returned by its gather function.
+	chk.Equal(root.SpanContext().SpanID(), secondGather.Parent.SpanID())
+	chk.Equal([]trace.SpanID{spanID(secondTask)}, links(secondGather))
+	chk.Equal(codes.Error, secondGather.Status.Code)
+}
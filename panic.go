@@ -0,0 +1,32 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg
+
+import (
+	"fmt"
+)
+
+// PanicError is passed to a [GatherFunc] in place of the error returned by a
+// [TaskFunc] that panicked, if the task was launched into a [Pool] created with
+// [WithPanicRecovery].
+type PanicError struct {
+	// Value is the value that was passed to panic.
+	Value any
+	// Stack is the stack trace of the panicking goroutine, as formatted by
+	// [runtime/debug.Stack].
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("task panicked: %v", e.Value)
+}
+
+// Unwrap returns Value if it is an error, so that [errors.Is] and [errors.As]
+// can see through to a panic that was raised with an error value.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
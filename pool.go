@@ -5,7 +5,9 @@ package psg
 
 import (
 	"context"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/petenewcomb/psg-go/internal/state"
 )
@@ -17,45 +19,207 @@ import (
 // The zero value of Pool is unbound and has a limit of zero. [NewPool]
 // provides a convenient way to create a new pool with a non-zero limit.
 type Pool struct {
-	limit    atomic.Int64
-	job      *Job
-	inFlight state.InFlightCounter
+	name          string
+	limit         atomic.Int64
+	rateLimiter   atomic.Pointer[rateLimiter]
+	job           atomic.Pointer[Job]
+	inFlight      state.InFlightCounter
+	recoverPanics bool
+	useWorkers    bool
+
+	// Statistics reported by Stats. Changes to them, and to the pool's limit,
+	// in-flight count, and waiters, are made with statsMu held so that Stats
+	// can take a consistent snapshot. The number of tasks whose gathers have
+	// been posted but not yet gathered or discarded is kept for Job.Stats, see
+	// jobStatsLocked.
+	statsMu  sync.Mutex
+	running  atomic.Int64
+	launched atomic.Uint64
+	gathered atomic.Uint64
+	ready    int
+	// The number of launches blocked on the pool itself, whereas the waiter
+	// counts include those blocked on its descendants.
+	blocked int
+
+	// Observers given by WithPoolObserver, and those combined with the
+	// observers of the pool's job by bindObservers.
+	observers    []PoolObserver
+	allObservers []PoolObserver
+
+	// The pool's parent, if any, and whether it has children, see
+	// hierarchy.go. Additions to the in-flight counts of pools in a hierarchy
+	// are serialized by the root's hierarchyMu.
+	parent      *Pool
+	hasChildren atomic.Bool
+	hierarchyMu sync.Mutex
+	children    atomic.Pointer[[]*Pool]
+
+	// The shared pool of which the pool is a member, if any, see shared.go.
+	shared *SharedPool
+
+	// The number of launches blocked on the pool or any of its descendants,
+	// and the priorities of those being held up by the pool itself, see
+	// waiters.go.
+	waiting atomic.Int64
+	waiters waiters
+
+	// Closed to wake the launches blocked on the pool when its capacity, or
+	// that of an ancestor, may have become available. Created on demand.
+	changeMu sync.Mutex
+	changed  chan struct{}
+
+	// Long-lived goroutines on which to run tasks, see worker.go.
+	workers atomic.Pointer[workers]
 }
 
-// Creates a new [Pool] with the given limit. See [Pool.SetLimit] for the range
-// of allowed values and their semantics.
-func NewPool(limit int) *Pool {
+// Creates a new [Pool] with the given limit and options. See [Pool.SetLimit]
+// for the range of allowed values and their semantics.
+func NewPool(limit int, opts ...PoolOption) *Pool {
 	p := &Pool{}
 	p.limit.Store(int64(limit))
+	for _, opt := range opts {
+		opt(p)
+	}
 	return p
 }
 
+// A PoolOption configures a [Pool] at creation time. See [NewPool].
+type PoolOption func(*Pool)
+
+// WithPanicRecovery returns a [PoolOption] that causes panics in any
+// [TaskFunc] launched into the pool to be recovered instead of terminating the
+// program. The recovered value and the stack trace of the panicking goroutine
+// are wrapped in a [*PanicError], which is then passed as the error argument to
+// the task's [GatherFunc] along with the zero value of the result type.
+func WithPanicRecovery() PoolOption {
+	return func(p *Pool) {
+		p.recoverPanics = true
+	}
+}
+
+// WithPoolName returns a [PoolOption] that names the pool. The name serves only
+// to identify the pool, for instance in metrics and traces reported by
+// observers (see [PoolObserver]), and need not be unique.
+func WithPoolName(name string) PoolOption {
+	return func(p *Pool) {
+		p.name = name
+	}
+}
+
+// Name returns the name given to the pool by [WithPoolName], or the empty
+// string if it has none.
+func (p *Pool) Name() string {
+	return p.name
+}
+
+// Limit returns the pool's current concurrency limit. See [Pool.SetLimit].
+func (p *Pool) Limit() int {
+	return int(p.limit.Load())
+}
+
 // Sets the active concurrency limit for the pool. A negative value means no
 // limit (tasks will always be launched regardless of how many are currently
 // running). Zero means no new tasks will be launched (i.e., [Scatter] will block
 // indefinitely) until SetLimit is called with a non-zero value. SetLimit is
 // always thread-safe, even for a Pool in a single-threaded [Job].
+//
+// More precisely, the limit bounds the total weight of the tasks running in the
+// pool. Each task has a weight of one unless scattered with [WithWeight], so by
+// default the limit is simply the maximum number of concurrent tasks.
 func (p *Pool) SetLimit(limit int) {
-	if p.limit.Swap(int64(limit)) == 0 && limit != 0 {
-		j := p.job
-		if j != nil {
-			j.wakeGatherers()
+	p.statsMu.Lock()
+	changed := p.limit.Swap(int64(limit)) != int64(limit)
+	p.statsMu.Unlock()
+	if changed {
+		p.notify()
+	}
+}
+
+// Returns a channel that will be closed the next time the capacity of the pool
+// or one of its ancestors may become available.
+func (p *Pool) awaitChange() <-chan struct{} {
+	p.changeMu.Lock()
+	defer p.changeMu.Unlock()
+	if p.changed == nil {
+		p.changed = make(chan struct{})
+	}
+	return p.changed
+}
+
+// Wakes any launches blocked on the pool or its descendants so that they
+// re-check its capacity.
+func (p *Pool) notify() {
+	p.changeMu.Lock()
+	if p.changed != nil {
+		close(p.changed)
+		p.changed = nil
+	}
+	p.changeMu.Unlock()
+	if children := p.children.Load(); children != nil {
+		for _, c := range *children {
+			// The waiting counts include those of descendants, so subtrees
+			// without blocked launches can be skipped.
+			if c.waiting.Load() > 0 {
+				c.notify()
+			}
 		}
 	}
 }
 
-func (p *Pool) launch(ctx context.Context, task boundTaskFunc, block bool) (bool, error) {
+// Wakes any launches blocked anywhere in the pool's hierarchy, as after
+// capacity has been released. Blocked launches register themselves as waiters
+// before their last check of capacity, so none can be missed.
+func (p *Pool) wakeWaiters() {
+	if root := p.root(); root.waiting.Load() > 0 {
+		root.notify()
+	}
+}
+
+func (p *Pool) launch(ctx context.Context, cfg *scatterConfig, task boundTaskFunc, block bool) (bool, error) {
+	return p.launchIn(ctx, p.launchJob(ctx), cfg, task, block)
+}
 
-	j := p.job
+// Returns the job into which the pool launches tasks, panicking if none may be
+// launched from the given context. These checks need only be made once for a
+// batch of launches from the same context, see ScatterSeq.
+func (p *Pool) launchJob(ctx context.Context) *Job {
+	j := p.job.Load()
 	if j == nil {
 		panic("pool not bound to a job")
 	}
 
+	for q := p.parent; q != nil; q = q.parent {
+		if q.job.Load() != j {
+			panic("parent pool not bound to the same job")
+		}
+	}
+
 	if j.isTaskContext(ctx) {
 		// Don't launch if the provided context is a task context within the
 		// current job, since that may lead to deadlock.
 		panic("psg.Scatter called from within TaskFunc; move call to GatherFunc instead")
 	}
+	return j
+}
+
+// Launches a task into the pool within the given job, as returned by
+// launchJob.
+func (p *Pool) launchIn(ctx context.Context, j *Job, cfg *scatterConfig, task boundTaskFunc, block bool) (bool, error) {
+
+	// The pool may have been removed from the job since launchJob was called.
+	if p.job.Load() != j {
+		return false, ErrPoolRemoved
+	}
+
+	// Note the time of the call only if someone is interested. The observers
+	// are captured here so that the task reports to those of the job it was
+	// launched in, even if the pool is later moved to another job.
+	observers := p.allObservers
+	observed := len(observers) > 0
+	var scatterTime time.Time
+	if observed {
+		scatterTime = time.Now()
+	}
 
 	// Don't launch if the provided context has been canceled.
 	if err := ctx.Err(); err != nil {
@@ -63,8 +227,8 @@ func (p *Pool) launch(ctx context.Context, task boundTaskFunc, block bool) (bool
 	}
 
 	// Don't launch if the job context has been canceled.
-	if err := j.ctx.Err(); err != nil {
-		return false, err
+	if j.ctx.Err() != nil {
+		return false, j.err()
 	}
 
 	// Register the task with the job to make sure that any calls to gather will
@@ -81,56 +245,247 @@ func (p *Pool) launch(ctx context.Context, task boundTaskFunc, block bool) (bool
 	}()
 
 	// Apply backpressure if launching a new task would exceed the pool's
-	// concurrency limit.
-	for !p.incrementInFlightIfUnderLimit() {
+	// concurrency limit or rate limit or the job's ordering window, or if a
+	// launch of higher priority is waiting.
+	var tokenWait time.Duration
+	// The pool holding up the launch when tryLaunch fails.
+	var heldBy *Pool
+	acquire := func() bool {
+		var ok bool
+		ok, heldBy, tokenWait = p.acquire(p.rateLimiter.Load(), cfg.weight)
+		return ok
+	}
+	var seq uint64
+	tryLaunch := func() bool {
+		tokenWait = 0
+		// A launch from within a gather function called by a blocked launch
+		// does not defer to waiting launches of higher priority, since the
+		// blocked launch may be one of them and is waiting for the gather
+		// function to return.
+		if q := p.waitingAbove(cfg.priority); q != nil && !j.isGatherContext(ctx) {
+			heldBy = q
+			return false
+		}
+		// If the job's ordering window refuses the launch, count it as held
+		// up by its own pool.
+		heldBy = p
+		var ok bool
+		seq, ok = j.reserveSeq(acquire)
+		return ok
+	}
+	waiting := false
+	var timer *time.Timer
+	if !tryLaunch() {
 		if !block {
 			return false, nil
 		}
-		// Gather a result to make room to launch the new task. As long as there
-		// wasn't an error, we don't care whether a task was actually gathered
-		// by this call. Either way, it's time to re-check the in-flight count
-		// for this pool.
-		if _, err := j.GatherOne(ctx); err != nil {
-			return false, err
+		p.addWaiter()
+		held := heldBy
+		held.addHeld(cfg.priority)
+		waiting = true
+		defer func() {
+			held.removeHeld(cfg.priority)
+			p.removeWaiter()
+			// Lower-priority launches may have been deferring to this one.
+			p.wakeWaiters()
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+		if observed {
+			p.scatterBlocked(ctx, cfg)
+		}
+		for {
+			// Get the change notification channels BEFORE checking again so
+			// that changes made after the check are not missed.
+			changed := p.awaitChange()
+			windowChanged := j.awaitWindowChange()
+			if tryLaunch() {
+				break
+			}
+			// Launches of lower priority need to defer to this one only where it
+			// is held up, so move it there if that has changed, and wake any
+			// that were deferring to it where it was.
+			if heldBy != held {
+				heldBy.addHeld(cfg.priority)
+				held.removeHeld(cfg.priority)
+				held.notify()
+				held = heldBy
+			}
+			// Nothing else signals when the rate limiter will next have a
+			// token, so arrange to re-check at that time.
+			if tokenWait > 0 {
+				if timer == nil {
+					timer = time.AfterFunc(tokenWait, p.notify)
+				} else {
+					timer.Reset(tokenWait)
+				}
+			}
+			// Gather a result to make room to launch the new task, or wait for
+			// capacity to be released. As long as there wasn't an error, we
+			// don't care whether a task was actually gathered by this call.
+			// Either way, it's time to re-check the in-flight count for this
+			// pool.
+			if ok, err := j.gatherOrWait(ctx, changed, windowChanged); err != nil && !(ok && j.errs.collect(err)) {
+				return false, err
+			}
+			if p.job.Load() != j {
+				return false, ErrPoolRemoved
+			}
+			// Check before getting new channels, which are often not needed
+			// after a gather.
+			if tryLaunch() {
+				break
+			}
 		}
 	}
 
-	// Launch the task in a new goroutine.
+	// Launch the task in a new goroutine or on a worker.
 	launched = true
-	j.wg.Add(1)
-	go func() {
-		defer j.wg.Done()
-		task(j.ctx)
-	}()
+	p.statsMu.Lock()
+	p.launched.Add(1)
+	p.running.Add(1)
+	p.statsMu.Unlock()
+	t := &launchedTask{
+		ctx:       j.ctx,
+		seq:       seq,
+		observers: observers,
+	}
+	if observed {
+		p.taskLaunched(ctx, cfg, t, scatterTime, waiting)
+	}
+	if p.useWorkers {
+		t.workers = p.workersFor(j)
+		t.workers.run(func() {
+			task(j, t)
+		})
+	} else {
+		j.wg.Add(1)
+		go j.runTask(task, t)
+	}
 
 	return true, nil
 }
 
-type boundTaskFunc func(ctx context.Context)
+type boundTaskFunc func(j *Job, t *launchedTask)
+
+// Runs a task launched into one of the job's pools on its own goroutine.
+func (j *Job) runTask(task boundTaskFunc, t *launchedTask) {
+	defer j.wg.Done()
+	task(j, t)
+}
+
+// The state of a task established at launch.
+type launchedTask struct {
+	// The context to pass to the task function.
+	ctx context.Context
+	// The sequence number reserved for the task by Job.reserveSeq.
+	seq uint64
+	// The observers to notify of the task's progress.
+	observers []PoolObserver
+	// The workers on which the task runs, if the pool uses them, and whether
+	// the worker running the task has been counted as free.
+	workers *workers
+	freed   atomic.Bool
+	// When the task was launched and finished, recorded only if the pool has
+	// observers.
+	launchTime time.Time
+	finishTime time.Time
+}
 
-func (p *Pool) incrementInFlightIfUnderLimit() bool {
+// Binds the pool to the given job, making the pool usable as a JobOption.
+func (p *Pool) applyToJob(j *Job) {
+	if !p.job.CompareAndSwap(nil, j) {
+		panic("pool was already registered")
+	}
+	j.pools = append(j.pools, p)
+}
+
+func (p *Pool) addInFlightIfWithinLimit(weight int) bool {
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
 	limit := p.limit.Load()
 	switch {
 	case limit < 0:
-		p.inFlight.Increment()
+		p.inFlight.Add(weight)
 		return true
 	case limit == 0:
 		return false
 	default:
-		return p.inFlight.IncrementIfUnder(int(limit))
+		return p.inFlight.AddIfWithin(weight, int(limit))
 	}
 }
 
-func (p *Pool) postGather(gather boundGatherFunc) {
-	// Decrement the pool's in-flight count BEFORE waiting on the gather
-	// channel. This makes it safe for gatherFunc to call `Scatter` with this
-	// same `Pool` instance without deadlock, as there is guaranteed to be at
-	// least one slot available.
-	p.inFlight.Decrement()
+// Records that a task is no longer running, either because its gather is about
+// to be posted, in which case it is counted as ready until gathered or
+// discarded, or because it was dropped. Releases the capacity it occupied in
+// the pool, its ancestors, and any shared pools.
+func (p *Pool) releaseTask(weight int, ready bool) {
+	p.statsMu.Lock()
+	p.running.Add(-1)
+	if ready {
+		p.ready++
+	}
+	p.inFlight.Subtract(weight)
+	p.statsMu.Unlock()
+	if p.shared != nil {
+		p.shared.release(p, weight)
+	}
+	for q := p.parent; q != nil; q = q.parent {
+		q.subtractOwnInFlight(weight)
+	}
+}
+
+// Records that a ready task's gather has been taken by a gatherer.
+func (p *Pool) gatherTaken() {
+	p.statsMu.Lock()
+	p.ready--
+	p.gathered.Add(1)
+	p.statsMu.Unlock()
+}
 
-	j := p.job
-	select {
-	case j.gatherChannel <- gather:
-	case <-j.ctx.Done():
+// Records that a ready task's gather was discarded due to cancellation of its
+// job, and notifies observers.
+func (p *Pool) gatherDiscarded(t *launchedTask) {
+	p.statsMu.Lock()
+	p.ready--
+	p.statsMu.Unlock()
+	p.resultDiscarded(t)
+}
+
+// Records that a task was not run because its job was canceled before it
+// started, releasing the capacity it occupied in the pool, its ancestors, and
+// any shared pools, as well as its worker.
+func (p *Pool) dropTask(cfg *scatterConfig, t *launchedTask, err error) {
+	p.releaseTask(cfg.weight, false)
+	p.wakeWaiters()
+	if t.workers != nil {
+		t.workers.taskGathered(t)
+	}
+	p.taskFinished(t, 0, err)
+}
+
+func (p *Pool) postGather(j *Job, cfg *scatterConfig, t *launchedTask, gather boundGatherFunc) {
+	// Decrement the pool's in-flight count BEFORE posting the gather. This
+	// makes it safe for gatherFunc to call `Scatter` with this same `Pool`
+	// instance without deadlock, as there is guaranteed to be at least one slot
+	// available. Posting the gather also wakes any launches waiting for this
+	// capacity.
+	p.releaseTask(cfg.weight, true)
+	if t.workers != nil {
+		gather = t.workers.wrapGather(t, gather)
+	}
+	var discard func()
+	if j.resultBuffer > 0 {
+		discard = func() {
+			p.gatherDiscarded(t)
+		}
+	}
+	if !j.postGather(p, p.observeGather(t, gather), cfg.priority, t.seq, discard) {
+		p.gatherDiscarded(t)
+	} else if t.workers != nil {
+		// The worker is free once its task's gather has been taken or
+		// buffered, whichever happened.
+		t.workers.taskGathered(t)
 	}
 }
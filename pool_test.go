@@ -0,0 +1,210 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/petenewcomb/psg-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolWeightedTasks(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(10)
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	release := make(chan struct{})
+	task := func(ctx context.Context) (int, error) {
+		<-release
+		return 0, nil
+	}
+	gather := func(ctx context.Context, result int, err error) error {
+		return err
+	}
+
+	ok, err := psg.TryScatter(ctx, pool, task, gather, psg.WithWeight(6))
+	chk.NoError(err)
+	chk.True(ok)
+
+	// Only 4 units of the limit remain.
+	ok, err = psg.TryScatter(ctx, pool, task, gather, psg.WithWeight(5))
+	chk.NoError(err)
+	chk.False(ok)
+	ok, err = psg.TryScatter(ctx, pool, task, gather, psg.WithWeight(4))
+	chk.NoError(err)
+	chk.True(ok)
+	ok, err = psg.TryScatter(ctx, pool, task, gather)
+	chk.NoError(err)
+	chk.False(ok)
+
+	// Once the running tasks finish, their full weight is released.
+	close(release)
+	chk.NoError(psg.Scatter(ctx, pool, task, gather, psg.WithWeight(10)))
+	chk.NoError(job.CloseAndGatherAll(ctx))
+}
+
+func TestPoolNonPositiveWeightPanic(t *testing.T) {
+	chk := require.New(t)
+	chk.PanicsWithValue("task weight must be positive", func() {
+		_ = psg.WithWeight(0)
+	})
+}
+
+func TestPoolPriorityLaunchOrder(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(1)
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	var mu sync.Mutex
+	var started []int
+	newTask := func(id int, release <-chan struct{}) psg.TaskFunc[int] {
+		return func(ctx context.Context) (int, error) {
+			mu.Lock()
+			started = append(started, id)
+			mu.Unlock()
+			<-release
+			return id, nil
+		}
+	}
+	gather := func(ctx context.Context, result int, err error) error {
+		return err
+	}
+
+	// Occupy the pool's only slot.
+	release := make(chan struct{})
+	chk.NoError(psg.Scatter(ctx, pool, newTask(0, release), gather))
+
+	// Block launches of increasing priority, waiting for each to block before
+	// starting the next.
+	done := make(chan error)
+	for id := 1; id <= 3; id++ {
+		go func() {
+			done <- psg.Scatter(ctx, pool, newTask(id, release), gather, psg.WithPriority(id))
+		}()
+		chk.Eventually(func() bool {
+			return pool.Stats().Blocked == id
+		}, time.Second, time.Millisecond)
+	}
+
+	close(release)
+	for range 3 {
+		chk.NoError(<-done)
+	}
+	chk.NoError(job.CloseAndGatherAll(ctx))
+	chk.Equal([]int{0, 3, 2, 1}, started)
+}
+
+func TestPoolPriorityScatterFromGather(t *testing.T) {
+	for _, priorities := range [][2]int{{1, 0}, {0, -1}} {
+		t.Run(fmt.Sprint(priorities), func(t *testing.T) {
+			chk := require.New(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			pool := psg.NewPool(1)
+			job := psg.NewJob(ctx, pool)
+			defer job.CancelAndWait()
+
+			task := func(ctx context.Context) (int, error) {
+				return 0, nil
+			}
+			gather := func(ctx context.Context, result int, err error) error {
+				return err
+			}
+
+			// The gather function of the first task scatters another task
+			// at lower priority than the blocked launch that gathers it. The
+			// first task finishes only once that launch is blocked.
+			chk.NoError(psg.Scatter(ctx, pool,
+				func(ctx context.Context) (int, error) {
+					for pool.Stats().Blocked == 0 {
+						time.Sleep(time.Millisecond)
+					}
+					return 0, nil
+				},
+				func(ctx context.Context, result int, err error) error {
+					return psg.Scatter(ctx, pool, task, gather, psg.WithPriority(priorities[1]))
+				},
+			))
+			chk.NoError(psg.Scatter(ctx, pool, task, gather, psg.WithPriority(priorities[0])))
+			chk.NoError(job.CloseAndGatherAll(ctx))
+			chk.Equal(uint64(3), pool.Stats().Gathered)
+		})
+	}
+}
+
+func TestPoolPriorityGatherOrder(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(-1)
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	var gathered []int
+	gather := func(ctx context.Context, result int, err error) error {
+		gathered = append(gathered, result)
+		return err
+	}
+	priorities := []int{0, 2, -1, 1}
+	for _, priority := range priorities {
+		chk.NoError(psg.Scatter(ctx, pool,
+			func(ctx context.Context) (int, error) {
+				return priority, nil
+			},
+			gather,
+			psg.WithPriority(priority),
+		))
+	}
+
+	// Wait for all tasks to complete before gathering any of them.
+	chk.Eventually(func() bool {
+		return job.Stats().Ready == len(priorities)
+	}, time.Second, time.Millisecond)
+	chk.NoError(job.CloseAndGatherAll(ctx))
+	chk.Equal([]int{2, 1, 0, -1}, gathered)
+}
+
+func TestPoolSetLimitWakesBlockedScatter(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(0)
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- psg.Scatter(ctx, pool,
+			func(ctx context.Context) (int, error) {
+				return 0, nil
+			},
+			func(ctx context.Context, result int, err error) error {
+				return err
+			},
+		)
+	}()
+	chk.Eventually(func() bool {
+		return pool.Stats().Blocked == 1
+	}, time.Second, time.Millisecond)
+	pool.SetLimit(1)
+	chk.NoError(<-errCh)
+	chk.NoError(job.CloseAndGatherAll(ctx))
+}
+
+func TestPoolNameAndLimit(t *testing.T) {
+	chk := require.New(t)
+	pool := psg.NewPool(3, psg.WithPoolName("io"))
+	chk.Equal("io", pool.Name())
+	chk.Equal(3, pool.Limit())
+	pool.SetLimit(-1)
+	chk.Equal(-1, pool.Limit())
+	chk.Empty(psg.NewPool(1).Name())
+}
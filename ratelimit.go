@@ -0,0 +1,121 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg
+
+import (
+	"sync"
+	"time"
+)
+
+// WithRateLimit returns a [PoolOption] that limits the rate at which tasks are
+// launched into the pool, in addition to its concurrency limit. See
+// [Pool.SetRateLimit].
+func WithRateLimit(rate float64, burst int) PoolOption {
+	return func(p *Pool) {
+		p.SetRateLimit(rate, burst)
+	}
+}
+
+// SetRateLimit sets the maximum sustained rate, in tasks per second, at which
+// tasks may be launched into the pool, along with the number of tasks that may
+// be launched in a burst after a period of inactivity. The limit follows the
+// semantics of a [token bucket] that holds up to burst tokens, is refilled at
+// the given rate, and from which each launch takes one token regardless of the
+// task's weight.
+//
+// [Scatter] applies the same backpressure to stay within the rate limit as it
+// does to stay within the concurrency limit, gathering other tasks while it
+// waits for a token. [TryScatter] declines to launch a task if no token is
+// available.
+//
+// A non-positive rate removes the rate limit, and a burst less than one is
+// treated as one. Like [Pool.SetLimit], SetRateLimit is always thread-safe.
+// Tokens accumulated under the previous rate limit are retained, up to the new
+// burst size; a pool that had no rate limit starts with a full bucket.
+//
+// [token bucket]: https://en.wikipedia.org/wiki/Token_bucket
+func (p *Pool) SetRateLimit(rate float64, burst int) {
+	var rl *rateLimiter
+	if rate > 0 {
+		rl = newRateLimiter(rate, max(burst, 1), p.rateLimiter.Load())
+	}
+	p.rateLimiter.Store(rl)
+	// Waiting launches may now be able to proceed sooner.
+	p.notify()
+}
+
+// A token bucket.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	// Protects the fields below.
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// Creates a rate limiter with a full bucket, or with the tokens of the given
+// previous limiter if non-nil.
+func newRateLimiter(rate float64, burst int, prev *rateLimiter) *rateLimiter {
+	rl := &rateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+	if prev != nil {
+		prev.mu.Lock()
+		defer prev.mu.Unlock()
+		prev.refillLocked(rl.last)
+		rl.tokens = min(prev.tokens, rl.burst)
+	}
+	return rl
+}
+
+func (rl *rateLimiter) refillLocked(now time.Time) {
+	if elapsed := now.Sub(rl.last); elapsed > 0 {
+		rl.tokens = min(rl.burst, rl.tokens+elapsed.Seconds()*rl.rate)
+		rl.last = now
+	}
+}
+
+// Takes a token if one is available. Otherwise, returns the time until one
+// will be.
+func (rl *rateLimiter) take() (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.refillLocked(time.Now())
+	if rl.tokens >= 1 {
+		rl.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - rl.tokens) / rl.rate * float64(time.Second))
+}
+
+// Returns a token taken for a launch that did not happen.
+func (rl *rateLimiter) refund() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.tokens = min(rl.burst, rl.tokens+1)
+}
+
+// Acquires a token from the given rate limiter, if any, and then capacity for
+// a task of the given weight. Returns false if either could not be acquired,
+// along with the pool that refused it and the time until a token will be
+// available if that was the reason.
+func (p *Pool) acquire(rl *rateLimiter, weight int) (bool, *Pool, time.Duration) {
+	if rl != nil {
+		if ok, wait := rl.take(); !ok {
+			return false, p, wait
+		}
+	}
+	if ok, refusedBy := p.addInFlightIfWithinLimits(weight); !ok {
+		if rl != nil {
+			rl.refund()
+		}
+		return false, refusedBy, 0
+	}
+	return true, nil, 0
+}
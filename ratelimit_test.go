@@ -0,0 +1,84 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/petenewcomb/psg-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(-1, psg.WithRateLimit(100, 2))
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	gathered := 0
+	task := func(ctx context.Context) (int, error) {
+		return 0, nil
+	}
+	gather := func(ctx context.Context, result int, err error) error {
+		gathered++
+		return err
+	}
+
+	// The burst is available immediately, but no more.
+	for range 2 {
+		ok, err := psg.TryScatter(ctx, pool, task, gather)
+		chk.NoError(err)
+		chk.True(ok)
+	}
+	ok, err := psg.TryScatter(ctx, pool, task, gather)
+	chk.NoError(err)
+	chk.False(ok)
+
+	// Further launches proceed at the sustained rate, gathering while they
+	// wait.
+	start := time.Now()
+	for range 5 {
+		chk.NoError(psg.Scatter(ctx, pool, task, gather))
+	}
+	chk.GreaterOrEqual(time.Since(start), 40*time.Millisecond)
+	chk.Positive(gathered)
+
+	// Removing the rate limit lets launches through immediately.
+	pool.SetRateLimit(0, 0)
+	for range 10 {
+		ok, err := psg.TryScatter(ctx, pool, task, gather)
+		chk.NoError(err)
+		chk.True(ok)
+	}
+	chk.NoError(job.CloseAndGatherAll(ctx))
+	chk.Equal(17, gathered)
+}
+
+func TestRateLimitWakesBlockedScatter(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(-1, psg.WithRateLimit(0.001, 1))
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	task := func(ctx context.Context) (int, error) {
+		return 0, nil
+	}
+	gather := func(ctx context.Context, result int, err error) error {
+		return err
+	}
+	chk.NoError(psg.Scatter(ctx, pool, task, gather))
+
+	// The next token would take over 15 minutes, but raising the rate makes
+	// it available sooner.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		pool.SetRateLimit(100, 1)
+	}()
+	chk.NoError(psg.Scatter(ctx, pool, task, gather))
+	chk.NoError(job.CloseAndGatherAll(ctx))
+}
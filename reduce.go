@@ -0,0 +1,172 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg
+
+import (
+	"context"
+)
+
+// Reduce calls fn concurrently for each of the given inputs like [Map], and
+// combines the results into one using the given combine function. Results are
+// combined as they are gathered rather than once all are available, so only
+// partial results awaiting their neighbors are held at any time.
+//
+// The combine function must be associative, but need not be commutative:
+// Reduce only ever combines the results of adjacent runs of inputs, with the
+// earlier run as the first argument, so the result is as if the results of all
+// the inputs had been combined in order. Reduce returns the zero value of R if
+// there are no inputs, and the result of the only input if there is one.
+//
+// By default, results are combined within the gather functions of the tasks
+// that call fn. Since gathering is not concurrent, this adds to backpressure
+// if combining is expensive (see [GatherFunc]). [WithCombinePool] instead
+// runs each combination as a task in a pool of its own, in which case the
+// combinations form a tree whose independent branches proceed concurrently.
+//
+// Reduce returns the first error returned by fn or combine or due to
+// cancellation of the given context, in which case the job is canceled and
+// any remaining calls are abandoned. See Map for the requirements on the
+// pool, which apply to any combine pool as well.
+func Reduce[I, R any](
+	ctx context.Context,
+	pool *Pool,
+	inputs []I,
+	fn func(context.Context, I) (R, error),
+	combine func(context.Context, R, R) (R, error),
+	opts ...ReduceOption,
+) (R, error) {
+	var cfg reduceConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	var zero R
+	jobOpts := []JobOption{pool}
+	if cfg.combinePool != nil && cfg.combinePool != pool {
+		jobOpts = append(jobOpts, cfg.combinePool)
+	}
+	job := NewJob(ctx, jobOpts...)
+	defer job.CancelAndWait()
+
+	r := &reducer[R]{
+		combine: combine,
+		pool:    cfg.combinePool,
+		byFirst: make(map[int]*reducePartial[R]),
+		byEnd:   make(map[int]*reducePartial[R]),
+	}
+	newTask := func(i int) TaskFunc[R] {
+		return func(ctx context.Context) (R, error) {
+			return fn(ctx, inputs[i])
+		}
+	}
+	newGather := func(i int) GatherFunc[R] {
+		return func(ctx context.Context, result R, err error) error {
+			if err != nil {
+				return err
+			}
+			return r.add(ctx, &reducePartial[R]{i, i + 1, result})
+		}
+	}
+	if err := ScatterSeq(ctx, pool, indices(len(inputs)), newTask, newGather, cfg.taskOpts...); err != nil {
+		return zero, err
+	}
+	if err := job.CloseAndGatherAll(ctx); err != nil {
+		return zero, err
+	}
+	if p := r.byFirst[0]; p != nil {
+		return p.value, nil
+	}
+	return zero, nil
+}
+
+// A ReduceOption configures a call to [Reduce].
+type ReduceOption func(*reduceConfig)
+
+type reduceConfig struct {
+	combinePool *Pool
+	taskOpts    []ScatterOption
+}
+
+// WithCombinePool returns a [ReduceOption] that makes [Reduce] launch each
+// combination of partial results as a task in the given pool rather than
+// performing it while gathering. The pool may be the same as the one given to
+// Reduce, in which case combinations compete with calls to fn for its limit.
+func WithCombinePool(pool *Pool) ReduceOption {
+	return func(cfg *reduceConfig) {
+		cfg.combinePool = pool
+	}
+}
+
+// WithTaskOptions returns a [ReduceOption] that applies the given options to
+// each of the tasks that [Reduce] launches to call fn.
+func WithTaskOptions(opts ...ScatterOption) ReduceOption {
+	return func(cfg *reduceConfig) {
+		cfg.taskOpts = append(cfg.taskOpts, opts...)
+	}
+}
+
+// Combines the partial results of a call to Reduce. Its methods are called
+// only from gather functions, so need no synchronization.
+type reducer[R any] struct {
+	combine func(context.Context, R, R) (R, error)
+	pool    *Pool
+	// The partial results not yet being combined, indexed by the first input
+	// they cover and by the input following the last one they cover.
+	byFirst map[int]*reducePartial[R]
+	byEnd   map[int]*reducePartial[R]
+}
+
+// The result of combining the results of inputs first through end-1.
+type reducePartial[R any] struct {
+	first int
+	end   int
+	value R
+}
+
+// Adds a partial result, combining it with those of its neighbors as they
+// become available.
+func (r *reducer[R]) add(ctx context.Context, p *reducePartial[R]) error {
+	for {
+		// Take a neighbor to combine with, if there is one. Any other
+		// neighbor will be combined with the result.
+		neighbor := r.byEnd[p.first]
+		a, b := neighbor, p
+		if neighbor == nil {
+			neighbor = r.byFirst[p.end]
+			a, b = p, neighbor
+		}
+		if neighbor == nil {
+			r.byFirst[p.first] = p
+			r.byEnd[p.end] = p
+			return nil
+		}
+		delete(r.byFirst, neighbor.first)
+		delete(r.byEnd, neighbor.end)
+
+		if r.pool == nil {
+			value, err := r.combine(ctx, a.value, b.value)
+			if err != nil {
+				return err
+			}
+			p = &reducePartial[R]{a.first, b.end, value}
+			continue
+		}
+
+		// Scattering may gather, and therefore add, other partial results
+		// before it returns, but a and b are no longer visible to them.
+		return Scatter(
+			ctx,
+			r.pool,
+			func(ctx context.Context) (R, error) {
+				return r.combine(ctx, a.value, b.value)
+			},
+			func(ctx context.Context, value R, err error) error {
+				if err != nil {
+					return err
+				}
+				return r.add(ctx, &reducePartial[R]{a.first, b.end, value})
+			},
+		)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/petenewcomb/psg-go"
+	"github.com/stretchr/testify/require"
+)
+
+// Formats each input, finishing out of order.
+func formatInput(ctx context.Context, input int) (string, error) {
+	time.Sleep(time.Duration(input%4) * time.Millisecond)
+	return fmt.Sprint(input, ","), nil
+}
+
+// Concatenation is associative but not commutative, so it reveals any
+// combination of results out of order.
+func concat(ctx context.Context, a, b string) (string, error) {
+	return a + b, nil
+}
+
+func reduceInputs(n int) ([]int, string) {
+	inputs := make([]int, n)
+	var want strings.Builder
+	for i := range inputs {
+		inputs[i] = i
+		fmt.Fprint(&want, i, ",")
+	}
+	return inputs, want.String()
+}
+
+func TestReduce(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(8)
+
+	inputs, want := reduceInputs(100)
+	result, err := psg.Reduce(ctx, pool, inputs, formatInput, concat)
+	chk.NoError(err)
+	chk.Equal(want, result)
+}
+
+func TestReduceCombinePool(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(8)
+	combinePool := psg.NewPool(4)
+
+	var combines atomic.Int64
+	combine := func(ctx context.Context, a, b string) (string, error) {
+		combines.Add(1)
+		return concat(ctx, a, b)
+	}
+
+	inputs, want := reduceInputs(100)
+	result, err := psg.Reduce(ctx, pool, inputs, formatInput, combine, psg.WithCombinePool(combinePool))
+	chk.NoError(err)
+	chk.Equal(want, result)
+	chk.Equal(int64(len(inputs)-1), combines.Load())
+	chk.Equal(uint64(len(inputs)-1), combinePool.Stats().Launched)
+
+	// Combinations may also share the pool of the tasks.
+	result, err = psg.Reduce(ctx, pool, inputs, formatInput, concat, psg.WithCombinePool(pool))
+	chk.NoError(err)
+	chk.Equal(want, result)
+}
+
+func TestReduceTaskOptions(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(2)
+
+	var attempts atomic.Int64
+	result, err := psg.Reduce(ctx, pool, []int{1, 2, 3},
+		func(ctx context.Context, input int) (int, error) {
+			if attempts.Add(1) == 1 {
+				return 0, errors.New("transient")
+			}
+			return input, nil
+		},
+		func(ctx context.Context, a, b int) (int, error) {
+			return a + b, nil
+		},
+		psg.WithTaskOptions(psg.WithRetry(psg.RetryPolicy{MaxAttempts: 2})),
+	)
+	chk.NoError(err)
+	chk.Equal(6, result)
+}
+
+func TestReduceTrivial(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(1)
+
+	result, err := psg.Reduce(ctx, pool, nil, formatInput, concat)
+	chk.NoError(err)
+	chk.Equal("", result)
+
+	result, err = psg.Reduce(ctx, pool, []int{7}, formatInput, concat)
+	chk.NoError(err)
+	chk.Equal("7,", result)
+}
+
+func TestReduceError(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(2)
+	inputs, _ := reduceInputs(20)
+
+	taskErr := errors.New("task failed")
+	result, err := psg.Reduce(ctx, pool, inputs,
+		func(ctx context.Context, input int) (string, error) {
+			if input == 5 {
+				return "", taskErr
+			}
+			return formatInput(ctx, input)
+		},
+		concat,
+	)
+	chk.ErrorIs(err, taskErr)
+	chk.Equal("", result)
+
+	combineErr := errors.New("combine failed")
+	for _, opts := range [][]psg.ReduceOption{nil, {psg.WithCombinePool(psg.NewPool(2))}} {
+		result, err = psg.Reduce(ctx, pool, inputs, formatInput,
+			func(ctx context.Context, a, b string) (string, error) {
+				return "", combineErr
+			},
+			opts...,
+		)
+		chk.ErrorIs(err, combineErr)
+		chk.Equal("", result)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// A RetryPolicy describes how a failed [TaskFunc] is re-run when scattered with
+// [WithRetry].
+//
+// Retries happen within the task's own goroutine and continue to occupy the
+// task's slot in its [Pool], including while waiting out the backoff between
+// attempts. The [GatherFunc] is called only once, with the result and error of
+// the final attempt. Use [Attempt] from within the GatherFunc to find out how
+// many attempts were made.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times the task will be run,
+	// including the first attempt. Values less than one are treated as one.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the second attempt.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between attempts. Zero means no cap.
+	MaxBackoff time.Duration
+
+	// Multiplier is the factor by which the delay grows after each attempt.
+	// Values less than one are treated as one, yielding a constant delay.
+	Multiplier float64
+
+	// Jitter is the fraction, between 0 and 1, of each delay that is chosen
+	// at random. For instance, a Jitter of 0.5 yields delays uniformly
+	// distributed between 50% and 100% of the computed backoff.
+	Jitter float64
+
+	// Retryable reports whether a task that returned the given non-nil error
+	// should be run again. If nil, all errors are considered retryable.
+	// Regardless of Retryable, a task is not retried once the job's context
+	// has been canceled.
+	Retryable func(error) bool
+}
+
+// WithRetry returns a [ScatterOption] that re-runs the task according to the
+// given policy whenever it returns a non-nil error.
+//
+// If combined with [WithTimeout], the timeout applies to each attempt
+// separately. A deadline set with [WithDeadline] applies to all attempts
+// together, including the backoff delays between them; if it expires, the
+// error passed to the [GatherFunc] wraps both [ErrTaskTimeout] and the error
+// returned by the last attempt.
+func WithRetry(policy RetryPolicy) ScatterOption {
+	return func(cfg *scatterConfig) {
+		cfg.retry = &policy
+	}
+}
+
+type attemptKeyType struct{}
+
+var attemptKey any = attemptKeyType{}
+
+// Attempt returns the attempt number associated with the given context. Within
+// a [TaskFunc] scattered with [WithRetry], it returns the number of the current
+// attempt, starting with one. Within the corresponding [GatherFunc], it returns
+// the total number of attempts that were made. For all other contexts,
+// including those passed to tasks and gather functions scattered without
+// WithRetry, it returns one.
+func Attempt(ctx context.Context) int {
+	if n, ok := ctx.Value(attemptKey).(int); ok {
+		return n
+	}
+	return 1
+}
+
+// Returns a context reporting the given attempt number via Attempt, avoiding
+// allocation if the context would already do so.
+func withAttempt(ctx context.Context, attempt int) context.Context {
+	if Attempt(ctx) == attempt {
+		return ctx
+	}
+	return context.WithValue(ctx, attemptKey, attempt)
+}
+
+// Reports whether another attempt should be made after the given attempt
+// failed with the given error.
+func (rp *RetryPolicy) shouldRetry(ctx context.Context, attempt int, err error) bool {
+	if attempt >= rp.MaxAttempts || ctx.Err() != nil {
+		return false
+	}
+	return rp.Retryable == nil || rp.Retryable(err)
+}
+
+// Waits for the backoff delay that follows the given attempt. Returns false if
+// the context was canceled first.
+func (rp *RetryPolicy) wait(ctx context.Context, attempt int) bool {
+	d := rp.backoff(attempt)
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (rp *RetryPolicy) backoff(attempt int) time.Duration {
+	d := float64(rp.InitialBackoff)
+	if d <= 0 {
+		return 0
+	}
+	if rp.Multiplier > 1 {
+		d *= math.Pow(rp.Multiplier, float64(attempt-1))
+	}
+	if rp.MaxBackoff > 0 && d > float64(rp.MaxBackoff) {
+		d = float64(rp.MaxBackoff)
+	}
+	// Without a MaxBackoff, the delay may grow beyond the longest Duration,
+	// even to infinity, so clamp it before applying jitter.
+	d = min(d, math.MaxInt64)
+	if jitter := min(max(rp.Jitter, 0), 1); jitter > 0 {
+		d -= d * jitter * rand.Float64()
+	}
+	if d >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(d)
+}
@@ -0,0 +1,145 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/petenewcomb/psg-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScatterWithRetry(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(1)
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	errTransient := errors.New("transient")
+	errPermanent := errors.New("permanent")
+	policy := psg.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		Multiplier:     2,
+		Jitter:         0.5,
+		Retryable: func(err error) bool {
+			return errors.Is(err, errTransient)
+		},
+	}
+
+	// Returns a task that fails with the given errors before succeeding.
+	newTask := func(errs ...error) (psg.TaskFunc[int], *atomic.Int64) {
+		var calls atomic.Int64
+		return func(ctx context.Context) (int, error) {
+			n := calls.Add(1)
+			if int(n) != psg.Attempt(ctx) {
+				return 0, errors.New("wrong attempt number")
+			}
+			if int(n) <= len(errs) {
+				return 0, errs[n-1]
+			}
+			return int(n), nil
+		}, &calls
+	}
+
+	type outcome struct {
+		result   int
+		err      error
+		attempts int
+	}
+	newGather := func(o *outcome) psg.GatherFunc[int] {
+		return func(ctx context.Context, result int, err error) error {
+			*o = outcome{result, err, psg.Attempt(ctx)}
+			return nil
+		}
+	}
+
+	// With a limit of one, each Scatter below can only succeed once the
+	// previous task's retries have completed and its slot was released.
+	var recovered, exhausted, permanent outcome
+	task, calls := newTask(errTransient, errTransient)
+	chk.NoError(psg.Scatter(ctx, pool, task, newGather(&recovered), psg.WithRetry(policy)))
+	ok, err := job.GatherOne(ctx)
+	chk.True(ok)
+	chk.NoError(err)
+	chk.Equal(outcome{3, nil, 3}, recovered)
+	chk.Equal(int64(3), calls.Load())
+
+	task, calls = newTask(errTransient, errTransient, errTransient)
+	chk.NoError(psg.Scatter(ctx, pool, task, newGather(&exhausted), psg.WithRetry(policy)))
+	task, _ = newTask(errPermanent)
+	chk.NoError(psg.Scatter(ctx, pool, task, newGather(&permanent), psg.WithRetry(policy)))
+	chk.NoError(job.CloseAndGatherAll(ctx))
+	chk.Equal(outcome{0, errTransient, 3}, exhausted)
+	chk.Equal(int64(3), calls.Load())
+	chk.Equal(outcome{0, errPermanent, 1}, permanent)
+}
+
+func TestRetryStopsAtDeadline(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(1)
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	errFails := errors.New("always fails")
+	var gatheredErr error
+	chk.NoError(psg.Scatter(ctx, pool,
+		func(ctx context.Context) (int, error) {
+			return 0, errFails
+		},
+		func(ctx context.Context, result int, err error) error {
+			gatheredErr = err
+			chk.Less(psg.Attempt(ctx), 1000)
+			return nil
+		},
+		psg.WithRetry(psg.RetryPolicy{
+			MaxAttempts:    1000,
+			InitialBackoff: 10 * time.Millisecond,
+		}),
+		psg.WithDeadline(time.Now().Add(25*time.Millisecond)),
+	))
+	chk.NoError(job.CloseAndGatherAll(ctx))
+	chk.ErrorIs(gatheredErr, psg.ErrTaskTimeout)
+	chk.ErrorIs(gatheredErr, errFails)
+}
+
+func TestRetryBackoffOverflow(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(1)
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	// Without a MaxBackoff, the backoff after the second attempt is far longer
+	// than a time.Duration can hold. It must not wrap around to a negative
+	// delay, which would let the remaining attempts run without any backoff.
+	for _, jitter := range []float64{0, 0.5} {
+		var attempts int
+		chk.NoError(psg.Scatter(ctx, pool,
+			func(ctx context.Context) (int, error) {
+				return 0, errors.New("always fails")
+			},
+			func(ctx context.Context, result int, err error) error {
+				attempts = psg.Attempt(ctx)
+				return nil
+			},
+			psg.WithRetry(psg.RetryPolicy{
+				MaxAttempts:    1000,
+				InitialBackoff: time.Nanosecond,
+				Multiplier:     1e300,
+				Jitter:         jitter,
+			}),
+			psg.WithDeadline(time.Now().Add(20*time.Millisecond)),
+		))
+		_, err := job.GatherOne(ctx)
+		chk.NoError(err)
+		chk.Equal(2, attempts)
+	}
+}
@@ -5,20 +5,24 @@ package psg
 
 import (
 	"context"
+	"runtime/debug"
+	"time"
 )
 
 // Scatter initiates asynchronous execution of the provided task function in a
-// new goroutine. After the task completes, the task's result and error will be
-// passed to the provided gather function within a subsequent call to Scatter or
-// any of the gathering methods of [Job] (i.e., [Job.GatherOne],
-// [Job.TryGatherOne], [Job.GatherAll], or [Job.TryGatherAll]).
+// new goroutine (or on a worker goroutine, see [WithWorkers]). After the task
+// completes, the task's result and error will be passed to the provided gather
+// function within a subsequent call to Scatter or any of the gathering methods
+// of [Job] (i.e., [Job.GatherOne], [Job.TryGatherOne], [Job.GatherAll], or
+// [Job.TryGatherAll]).
 //
 // Scatter blocks to delay launch as needed to ensure compliance with the
-// concurrency limit for the given pool. This backpressure is applied by
-// gathering other tasks in the job until the a slot becomes available. The
-// context passed to Scatter may be used to cancel (e.g., with a timeout) both
-// gathering and launch, but only the context associated with the pool's job
-// will be passed to the task.
+// concurrency limit and any rate limit (see [Pool.SetRateLimit]) for the given
+// pool. This backpressure is applied by gathering other tasks in the job until
+// the a slot becomes available. The context passed to Scatter may be used to
+// cancel (e.g., with a timeout) both gathering and launch, but only the context
+// associated with the pool's job, or a context derived from it by options like
+// [WithTimeout], will be passed to the task.
 //
 // WARNING: Scatter must not be called from within a TaskFunc launched the same
 // job as this may lead to deadlock when a concurrency limit is reached.
@@ -27,18 +31,23 @@ import (
 //
 // Scatter will panic if the given pool is not yet associated with a job.
 // Scatter returns a non-nil error if the context is canceled or if a non-nil
-// error is returned by a gather function. If the returned error is non-nil, the
+// error is returned by a gather function (unless the job was created with
+// [WithErrorAggregation]). If the returned error is non-nil, the
 // task function supplied to the call will not have been launched will therefore
 // also not result in a call to the supplied gather function.
 //
+// Options may be supplied to configure the individual task, for instance
+// [WithTimeout], [WithDeadline], or [WithRetry].
+//
 // See [TaskFunc] and [GatherFunc] for important caveats and additional detail.
 func Scatter[T any](
 	ctx context.Context,
 	pool *Pool,
 	taskFunc TaskFunc[T],
 	gatherFunc GatherFunc[T],
+	opts ...ScatterOption,
 ) error {
-	_, err := scatter(ctx, pool, taskFunc, gatherFunc, true)
+	_, err := scatter(ctx, pool, taskFunc, gatherFunc, true, opts)
 	return err
 }
 
@@ -48,7 +57,8 @@ func Scatter[T any](
 // limit.
 //
 // Returns (true, nil) if the task was successfully launched, (false, nil) if
-// the pool was at its limit, and (false, non-nil) if the task could not be
+// the pool was at its limit (or at its rate limit, or the job's window was
+// full, see [WithOrderedGather]), and (false, non-nil) if the task could not be
 // launched for any other reason.
 //
 // See Scatter for more detail about how scattering works.
@@ -57,8 +67,68 @@ func TryScatter[T any](
 	pool *Pool,
 	taskFunc TaskFunc[T],
 	gatherFunc GatherFunc[T],
+	opts ...ScatterOption,
 ) (bool, error) {
-	return scatter(ctx, pool, taskFunc, gatherFunc, false)
+	return scatter(ctx, pool, taskFunc, gatherFunc, false, opts)
+}
+
+// A ScatterOption configures an individual task launched by [Scatter] or
+// [TryScatter].
+type ScatterOption func(*scatterConfig)
+
+type scatterConfig struct {
+	timeout  time.Duration
+	deadline time.Time
+	retry    *RetryPolicy
+	weight   int
+	priority int
+}
+
+// The configuration of tasks scattered without options, shared by all of them
+// and never modified.
+var defaultScatterConfig = scatterConfig{
+	weight: 1,
+}
+
+// WithWeight returns a [ScatterOption] that sets the weight of the task, which
+// is the amount of its pool's limit that the task occupies while it runs. See
+// [Pool.SetLimit]. This allows a pool to bound resources like memory or
+// connections that tasks consume in differing amounts, similar to
+// [semaphore.Weighted].
+//
+// The weight must be positive and defaults to one. A task whose weight exceeds
+// its pool's limit will not be launched until the limit is raised; Scatter will
+// block indefinitely in the meantime, as it does when the limit is zero.
+//
+// [semaphore.Weighted]: https://pkg.go.dev/golang.org/x/sync/semaphore#Weighted
+func WithWeight(weight int) ScatterOption {
+	if weight < 1 {
+		panic("task weight must be positive")
+	}
+	return func(cfg *scatterConfig) {
+		cfg.weight = weight
+	}
+}
+
+// WithPriority returns a [ScatterOption] that sets the priority of the task.
+// Higher values take precedence, and the default is zero.
+//
+// Priority matters in two places. First, when the task's pool is at its limit,
+// blocked launches of higher priority are given freed capacity before those of
+// lower priority, even if a lower-priority launch could otherwise fit. Second,
+// when several completed tasks are waiting to be gathered, those of higher
+// priority are gathered first. Among equal priorities, launch order is
+// unspecified and completed tasks are gathered in the order they completed.
+//
+// Priority is strict, so a steady stream of high-priority work can starve
+// lower-priority work indefinitely. The one exception is that a call to Scatter
+// from within a [GatherFunc] run by a blocked call to Scatter does not wait for
+// blocked launches of higher priority, since the blocked call may itself be
+// one of them.
+func WithPriority(priority int) ScatterOption {
+	return func(cfg *scatterConfig) {
+		cfg.priority = priority
+	}
 }
 
 func scatter[T any](
@@ -67,7 +137,34 @@ func scatter[T any](
 	taskFunc TaskFunc[T],
 	gatherFunc GatherFunc[T],
 	block bool,
+	opts []ScatterOption,
 ) (bool, error) {
+	cfg := newScatterConfig(opts)
+	return pool.launch(ctx, cfg, bindTask(pool, cfg, taskFunc, gatherFunc), block)
+}
+
+// Applies the given options to a new scatter configuration.
+func newScatterConfig(opts []ScatterOption) *scatterConfig {
+	if len(opts) == 0 {
+		return &defaultScatterConfig
+	}
+	cfg := &scatterConfig{
+		weight: 1,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// Binds the task and gather functions together into a top-level function for
+// the new goroutine, to be handed to the pool to launch.
+func bindTask[T any](
+	pool *Pool,
+	cfg *scatterConfig,
+	taskFunc TaskFunc[T],
+	gatherFunc GatherFunc[T],
+) boundTaskFunc {
 	if taskFunc == nil {
 		panic("task function must be non-nil")
 	}
@@ -75,35 +172,104 @@ func scatter[T any](
 		panic("gather function must be non-nil")
 	}
 
-	// Bind the task and gather functions together into a top-level function for
-	// the new goroutine and hand it to the pool to launch.
-	return pool.launch(ctx, func(ctx context.Context) {
+	return func(j *Job, t *launchedTask) {
+		ctx := t.ctx
+
 		// Don't launch if the context has been canceled by the time the
 		// goroutine starts.
 		if ctx.Err() != nil {
+			pool.dropTask(cfg, t, context.Cause(ctx))
 			return
 		}
 
 		// Actually execute the task function. Since this is the top-level
 		// function of a goroutine, if the task function panics the whole
-		// program will terminate. The user can avoid this behavior by
-		// recovering from the panic within the task function itself and then
-		// returning normally with whatever results they want to pass to the
-		// GatherFunc to represent the failure. We therefore do not defer
-		// posting a gather to the job's channel or otherwise attempt to
-		// maintain the integrity of the pool or overall job in case of task
-		// panics.
-		value, err := taskFunc(ctx)
+		// program will terminate unless the pool was configured to recover
+		// from panics. We therefore do not defer posting a gather to the job's
+		// channel or otherwise attempt to maintain the integrity of the pool or
+		// overall job in case of unrecovered task panics.
+		value, attempts, err := runTask(ctx, cfg, taskFunc, pool.recoverPanics)
+		pool.taskFinished(t, attempts, err)
+		if err != nil {
+			j.taskFailed(err)
+		}
 
 		// Build the gather function, binding the supplied gatherFunc to the
 		// result.
 		gather := func(ctx context.Context) error {
-			return gatherFunc(ctx, value, err)
+			return gatherFunc(withAttempt(ctx, attempts), value, err)
 		}
 
 		// Post the gather to the gather channel.
-		pool.postGather(gather)
-	}, block)
+		pool.postGather(j, cfg, t, gather)
+	}
+}
+
+// Runs the task function according to the scatter configuration, making
+// multiple attempts if a retry policy was given. Returns the result of the
+// final attempt along with the number of attempts made.
+func runTask[T any](
+	ctx context.Context,
+	cfg *scatterConfig,
+	taskFunc TaskFunc[T],
+	recoverPanics bool,
+) (T, int, error) {
+	if cfg.retry == nil {
+		value, err := runAttempt(ctx, cfg, taskFunc, recoverPanics)
+		return value, 1, err
+	}
+
+	// Apply any deadline across all attempts, including the backoff delays
+	// between them, rather than to each one.
+	if !cfg.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadlineCause(ctx, cfg.deadline, ErrTaskTimeout)
+		defer cancel()
+	}
+	for attempt := 1; ; attempt++ {
+		value, err := runAttempt(withAttempt(ctx, attempt), cfg, taskFunc, recoverPanics)
+		if err == nil || !cfg.retry.shouldRetry(ctx, attempt, err) || !cfg.retry.wait(ctx, attempt) {
+			// The deadline may have expired after the attempt returned,
+			// while deciding whether to retry or waiting out the backoff.
+			return value, attempt, wrapTaskTimeout(ctx, err)
+		}
+	}
+}
+
+// Runs a single attempt of the task function, applying any per-task timeout or
+// deadline.
+func runAttempt[T any](
+	ctx context.Context,
+	cfg *scatterConfig,
+	taskFunc TaskFunc[T],
+	recoverPanics bool,
+) (T, error) {
+	ctx, cancel := cfg.taskContext(ctx)
+	defer cancel()
+	value, err := callTask(ctx, taskFunc, recoverPanics)
+	return value, wrapTaskTimeout(ctx, err)
+}
+
+// Calls the task function, converting any panic into a *PanicError if
+// recoverPanics is true.
+func callTask[T any](
+	ctx context.Context,
+	taskFunc TaskFunc[T],
+	recoverPanics bool,
+) (value T, err error) {
+	if recoverPanics {
+		defer func() {
+			if r := recover(); r != nil {
+				var zero T
+				value = zero
+				err = &PanicError{
+					Value: r,
+					Stack: debug.Stack(),
+				}
+			}
+		}()
+	}
+	return taskFunc(ctx)
 }
 
 // A TaskFunc represents a task to be executed asynchronously within the context
@@ -114,15 +280,16 @@ func scatter[T any](
 // closure].
 //
 // Each TaskFunc is executed in a new goroutine spawned by the [Scatter]
-// function and must therefore be thread-safe. This includes access to any
-// captured variables.
+// function (or on a worker goroutine, see [WithWorkers]) and must therefore be
+// thread-safe. This includes access to any captured variables.
 //
 // Also because they are executed in their own goroutines, if a TaskFunc panics,
 // the whole program will terminate as per [Handling panics] in The Go
 // Programming Language Specification. If you need to avoid this behavior,
-// recover from the panic within the task function itself and then return
-// whatever results you want to passed to the associated [GatherFunc] to
-// represent the failure.
+// either create the pool with [WithPanicRecovery], which converts the panic
+// into a [*PanicError] passed to the associated [GatherFunc], or recover from
+// the panic within the task function itself and then return whatever results
+// you want to pass to the associated [GatherFunc] to represent the failure.
 //
 // WARNING: If a TaskFunc needs to spawn new tasks, it must not call [Scatter]
 // directly as this would lead to deadlock when a concurrency limit is reached.
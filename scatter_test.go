@@ -5,6 +5,7 @@ package psg_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/petenewcomb/psg-go"
@@ -263,3 +264,42 @@ func TestTaskCannotScatterToParentJob(t *testing.T) {
 	chk.NoError(err)
 	chk.NoError(parentJob.CloseAndGatherAll(ctx))
 }
+
+func TestPanicRecovery(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(1, psg.WithPanicRecovery())
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	sentinel := errors.New("sentinel")
+	panicValues := []any{"boom", sentinel}
+	var gathered []error
+	for _, v := range panicValues {
+		// With a limit of one, the second Scatter can only succeed if the
+		// first task's slot was released despite the panic.
+		err := psg.Scatter(
+			ctx,
+			pool,
+			func(ctx context.Context) (int, error) {
+				panic(v)
+			},
+			func(ctx context.Context, result int, err error) error {
+				chk.Equal(0, result)
+				gathered = append(gathered, err)
+				return nil
+			},
+		)
+		chk.NoError(err)
+	}
+	chk.NoError(job.CloseAndGatherAll(ctx))
+
+	chk.Len(gathered, len(panicValues))
+	for i, err := range gathered {
+		var pe *psg.PanicError
+		chk.ErrorAs(err, &pe)
+		chk.Equal(panicValues[i], pe.Value)
+		chk.NotEmpty(pe.Stack)
+	}
+	chk.ErrorIs(gathered[1], sentinel)
+}
@@ -0,0 +1,232 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// A SharedPool is a concurrency limit shared by pools that may be bound to
+// different, concurrently running jobs. Each such member pool is created with
+// [WithSharedPool], and a task launched into a member pool occupies its weight
+// (see [WithWeight]) in both the member pool's limit and the shared pool's
+// limit. This allows, for instance, a process-wide limit on database queries
+// to be enforced across the jobs of all requests being handled:
+//
+//	var dbQueries = psg.NewSharedPool(16, psg.EqualShare)
+//
+//	func handle(ctx context.Context, req *Request) error {
+//		pool := psg.NewPool(-1, psg.WithSharedPool(dbQueries))
+//		job := psg.NewJob(ctx, pool)
+//		defer job.CancelAndWait()
+//		...
+//	}
+//
+// A SharedPool is not itself bound to any job, and tasks cannot be launched
+// into it directly. Priorities given by [WithPriority] apply only among the
+// launches into each member pool; how the shared limit is divided among member
+// pools is instead determined by the pool's [Fairness].
+type SharedPool struct {
+	limit    atomic.Int64
+	fairness Fairness
+
+	// Protects the fields below.
+	mu sync.Mutex
+	// The total weight of the tasks running in member pools.
+	weight int
+	// The member pools with running or blocked tasks.
+	members map[*Pool]*sharedPoolMember
+}
+
+type sharedPoolMember struct {
+	// The total weight of the tasks running in the member pool.
+	weight int
+	// The number of launches blocked on the member pool.
+	waiting int
+	// Whether a launch into the member pool was refused since the member
+	// pool was last woken. The launch may not yet be counted as waiting, but
+	// must still be woken once capacity becomes available.
+	refused bool
+}
+
+// Fairness determines how a [SharedPool] divides its limit among its member
+// pools when they contend for it.
+type Fairness int
+
+const (
+	// Unfair makes no attempt to divide a shared pool's limit among its
+	// member pools. Capacity goes to whichever launch claims it first, so a
+	// busy job may starve others.
+	Unfair Fairness = iota
+
+	// EqualShare divides a shared pool's limit equally among the member pools
+	// that have tasks running or launches blocked. A member pool may use more
+	// than its share only while no other member pool with blocked launches is
+	// using less than its share, so capacity is never left idle. A member
+	// pool's tasks are never preempted, however, so a member pool may remain
+	// over its share until enough of its running tasks have finished.
+	EqualShare
+)
+
+// NewSharedPool creates a new [SharedPool] with the given limit and fairness.
+// See [Pool.SetLimit] for the range of allowed limits and their semantics.
+func NewSharedPool(limit int, fairness Fairness) *SharedPool {
+	s := &SharedPool{
+		fairness: fairness,
+	}
+	s.limit.Store(int64(limit))
+	return s
+}
+
+// WithSharedPool returns a [PoolOption] that makes the pool a member of the
+// given [SharedPool], such that tasks launched into the pool also count against
+// the shared pool's limit.
+func WithSharedPool(s *SharedPool) PoolOption {
+	if s == nil {
+		panic("shared pool must be non-nil")
+	}
+	return func(p *Pool) {
+		p.shared = s
+	}
+}
+
+// Limit returns the shared pool's current limit. See [SharedPool.SetLimit].
+func (s *SharedPool) Limit() int {
+	return int(s.limit.Load())
+}
+
+// SetLimit sets the limit of the shared pool. See [Pool.SetLimit] for the range
+// of allowed values and their semantics. Like Pool.SetLimit, it is always
+// thread-safe.
+func (s *SharedPool) SetLimit(limit int) {
+	if s.limit.Swap(int64(limit)) != int64(limit) {
+		s.mu.Lock()
+		waiting := s.waitingLocked()
+		s.mu.Unlock()
+		notifyAll(waiting)
+	}
+}
+
+// Weight returns the total weight of the tasks currently running in the shared
+// pool's member pools, which is what its limit bounds.
+func (s *SharedPool) Weight() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.weight
+}
+
+// Returns the given member pool's state, creating it if necessary.
+func (s *SharedPool) memberLocked(p *Pool) *sharedPoolMember {
+	m := s.members[p]
+	if m == nil {
+		m = &sharedPoolMember{}
+		if s.members == nil {
+			s.members = make(map[*Pool]*sharedPoolMember)
+		}
+		s.members[p] = m
+	}
+	return m
+}
+
+// Forgets the given member pool's state once it is idle, so that member pools
+// created for short-lived jobs do not accumulate.
+func (s *SharedPool) pruneLocked(p *Pool, m *sharedPoolMember) {
+	if m.weight == 0 && m.waiting == 0 && !m.refused {
+		delete(s.members, p)
+	}
+}
+
+// Returns the member pools with blocked or refused launches, which are to be
+// woken, and clears their refused flags.
+func (s *SharedPool) waitingLocked() []*Pool {
+	var pools []*Pool
+	for p, m := range s.members {
+		if m.waiting > 0 || m.refused {
+			pools = append(pools, p)
+			m.refused = false
+			s.pruneLocked(p, m)
+		}
+	}
+	return pools
+}
+
+// Wakes the launches blocked on the given pools, which may be bound to
+// different jobs.
+func notifyAll(pools []*Pool) {
+	for _, p := range pools {
+		p.notify()
+	}
+}
+
+// Adds the given weight on behalf of the given member pool if it fits within
+// the shared pool's limit and the member pool's fair share.
+func (s *SharedPool) acquire(p *Pool, weight int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	limit := int(s.limit.Load())
+	if limit == 0 ||
+		limit > 0 && s.weight+weight > limit ||
+		limit > 0 && s.fairness == EqualShare && !s.withinShareLocked(p, weight, limit) {
+		s.memberLocked(p).refused = true
+		return false
+	}
+	s.weight += weight
+	s.memberLocked(p).weight += weight
+	return true
+}
+
+// Reports whether the given member pool may add the given weight under
+// EqualShare fairness.
+func (s *SharedPool) withinShareLocked(p *Pool, weight int, limit int) bool {
+	active := len(s.members)
+	m := s.members[p]
+	if m == nil {
+		active++
+		m = &sharedPoolMember{}
+	}
+	share := float64(limit) / float64(active)
+	if float64(m.weight+weight) <= share {
+		return true
+	}
+	for q, o := range s.members {
+		if q != p && o.waiting > 0 && float64(o.weight) < share {
+			return false
+		}
+	}
+	return true
+}
+
+// Subtracts the given weight on behalf of the given member pool, waking any
+// launches blocked on other member pools.
+func (s *SharedPool) release(p *Pool, weight int) {
+	s.mu.Lock()
+	s.releaseLocked(p, weight)
+	waiting := s.waitingLocked()
+	s.mu.Unlock()
+	notifyAll(waiting)
+}
+
+func (s *SharedPool) releaseLocked(p *Pool, weight int) {
+	s.weight -= weight
+	m := s.memberLocked(p)
+	m.weight -= weight
+	s.pruneLocked(p, m)
+}
+
+// Records a change in the number of launches blocked on the given member pool.
+func (s *SharedPool) addWaiting(p *Pool, delta int) {
+	s.mu.Lock()
+	m := s.memberLocked(p)
+	m.waiting += delta
+	s.pruneLocked(p, m)
+	var waiting []*Pool
+	if delta < 0 && s.fairness == EqualShare {
+		// Launches into other member pools may have been deferring to this
+		// pool's, and the number of pools sharing the limit may have changed.
+		waiting = s.waitingLocked()
+	}
+	s.mu.Unlock()
+	notifyAll(waiting)
+}
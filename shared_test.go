@@ -0,0 +1,178 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/petenewcomb/psg-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharedPool(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	shared := psg.NewSharedPool(3, psg.Unfair)
+	poolA := psg.NewPool(2, psg.WithSharedPool(shared))
+	poolB := psg.NewPool(-1, psg.WithSharedPool(shared))
+	jobA := psg.NewJob(ctx, poolA)
+	defer jobA.CancelAndWait()
+	jobB := psg.NewJob(ctx, poolB)
+	defer jobB.CancelAndWait()
+
+	release := make(chan struct{})
+	task := func(ctx context.Context) (int, error) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return 0, nil
+	}
+	gather := func(ctx context.Context, result int, err error) error {
+		return err
+	}
+	tryScatter := func(pool *psg.Pool) bool {
+		ok, err := psg.TryScatter(ctx, pool, task, gather)
+		chk.NoError(err)
+		return ok
+	}
+
+	// The member pool's own limit applies.
+	chk.True(tryScatter(poolA))
+	chk.True(tryScatter(poolA))
+	chk.False(tryScatter(poolA))
+
+	// The shared limit applies across jobs.
+	chk.True(tryScatter(poolB))
+	chk.False(tryScatter(poolB))
+	chk.Equal(3, shared.Weight())
+
+	// A launch blocked in one job is woken when a task finishes in another.
+	errCh := make(chan error)
+	go func() {
+		errCh <- psg.Scatter(ctx, poolB, task, gather)
+	}()
+	chk.Eventually(func() bool {
+		return poolB.Stats().Blocked == 1
+	}, time.Second, time.Millisecond)
+	close(release)
+	chk.NoError(<-errCh)
+
+	chk.NoError(jobA.CloseAndGatherAll(ctx))
+	chk.NoError(jobB.CloseAndGatherAll(ctx))
+	chk.Equal(0, shared.Weight())
+}
+
+func TestSharedPoolEqualShare(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	shared := psg.NewSharedPool(2, psg.EqualShare)
+	poolA := psg.NewPool(-1, psg.WithSharedPool(shared))
+	poolB := psg.NewPool(-1, psg.WithSharedPool(shared))
+	jobA := psg.NewJob(ctx, poolA)
+	defer jobA.CancelAndWait()
+	jobB := psg.NewJob(ctx, poolB)
+	defer jobB.CancelAndWait()
+
+	gather := func(ctx context.Context, result int, err error) error {
+		return err
+	}
+	releaseA := make(chan struct{})
+	taskA := func(ctx context.Context) (int, error) {
+		select {
+		case <-releaseA:
+		case <-ctx.Done():
+		}
+		return 0, nil
+	}
+	releaseB := make(chan struct{})
+	taskB := func(ctx context.Context) (int, error) {
+		select {
+		case <-releaseB:
+		case <-ctx.Done():
+		}
+		return 0, nil
+	}
+
+	// Without contention, one job may use the whole limit.
+	for range 2 {
+		ok, err := psg.TryScatter(ctx, poolA, taskA, gather)
+		chk.NoError(err)
+		chk.True(ok)
+	}
+
+	// Once another job is waiting, capacity freed by the first job goes to
+	// the other job until it has its share.
+	errCh := make(chan error)
+	go func() {
+		errCh <- psg.Scatter(ctx, poolB, taskB, gather)
+	}()
+	chk.Eventually(func() bool {
+		return poolB.Stats().Blocked == 1
+	}, time.Second, time.Millisecond)
+	releaseA <- struct{}{}
+	ok, err := jobA.GatherOne(ctx)
+	chk.NoError(err)
+	chk.True(ok)
+	ok, err = psg.TryScatter(ctx, poolA, taskA, gather)
+	chk.NoError(err)
+	chk.False(ok)
+	chk.NoError(<-errCh)
+	chk.Equal(1, poolA.Stats().Weight)
+	chk.Equal(1, poolB.Stats().Weight)
+
+	close(releaseA)
+	close(releaseB)
+	chk.NoError(jobA.CloseAndGatherAll(ctx))
+	chk.NoError(jobB.CloseAndGatherAll(ctx))
+	chk.Equal(0, shared.Weight())
+}
+
+func TestSharedPoolReleasedByCanceledJob(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	const limit = 4
+	shared := psg.NewSharedPool(limit, psg.EqualShare)
+
+	blocking := func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+	quick := func(ctx context.Context) (int, error) {
+		return 1, nil
+	}
+	ignore := func(ctx context.Context, result int, err error) error {
+		return nil
+	}
+
+	// Cancel jobs, as a request handler might, while their tasks are still
+	// pending: some are blocked running, and others may not have started yet
+	// or be waiting to be gathered. Each must release its share.
+	for range 20 {
+		pool := psg.NewPool(-1, psg.WithSharedPool(shared))
+		job := psg.NewJob(ctx, pool)
+		for i := range limit {
+			task := quick
+			if i%2 == 0 {
+				task = blocking
+			}
+			chk.NoError(psg.Scatter(ctx, pool, task, ignore))
+		}
+		job.CancelAndWait()
+		chk.Zero(shared.Weight())
+	}
+
+	// A later job can still use the whole shared limit.
+	pool := psg.NewPool(-1, psg.WithSharedPool(shared))
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+	for range limit {
+		ok, err := psg.TryScatter(ctx, pool, blocking, ignore)
+		chk.NoError(err)
+		chk.True(ok)
+	}
+	chk.Equal(limit, shared.Weight())
+}
@@ -15,6 +15,17 @@ import (
 )
 
 func TestBySimulation(t *testing.T) {
+	testBySimulation(t, sim.Run)
+}
+
+func TestBySimulationWithWorkers(t *testing.T) {
+	testBySimulation(t, sim.RunWithWorkers)
+}
+
+func testBySimulation(
+	t *testing.T,
+	run func(require.TestingT, context.Context, *sim.Plan, bool) (map[*sim.Plan]*sim.Result, error),
+) {
 	rapid.Check(t, func(t *rapid.T) {
 		// Build a simulation plan
 		planConfig := sim.NewPlanConfig(t)
@@ -82,7 +93,7 @@ func TestBySimulation(t *testing.T) {
 		chk := require.New(t)
 		observations := make(map[*sim.Plan]*sim.ResultRange)
 		for trial := range warmUpCount + trialCount {
-			resultMap, err := sim.Run(t, ctx, plan, debug)
+			resultMap, err := run(t, ctx, plan, debug)
 			chk.NoError(err)
 			if trial >= warmUpCount {
 				sim.MergeResultMap(t, observations, resultMap)
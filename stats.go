@@ -0,0 +1,129 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg
+
+// PoolStats is a snapshot of the state of a [Pool], as returned by
+// [Pool.Stats].
+type PoolStats struct {
+	// Limit is the pool's current limit. See [Pool.SetLimit].
+	Limit int
+	// InFlight is the number of tasks in the pool that are currently running.
+	InFlight int
+	// Weight is the total weight of the tasks that are currently running in
+	// the pool, which is what the limit bounds. See [WithWeight]. It includes
+	// the weight of tasks running in the pool's descendants (see
+	// [WithParent]).
+	Weight int
+	// Blocked is the number of calls to [Scatter] currently waiting to launch
+	// a task into the pool or any of its descendants.
+	Blocked int
+	// Launched is the total number of tasks launched into the pool.
+	Launched uint64
+	// Gathered is the total number of tasks from the pool whose results have
+	// been gathered.
+	Gathered uint64
+}
+
+// Stats returns a consistent snapshot of the pool's state. It is thread-safe,
+// and briefly blocks changes to the pool's state while the snapshot is taken.
+func (p *Pool) Stats() PoolStats {
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+	return p.statsLocked()
+}
+
+func (p *Pool) statsLocked() PoolStats {
+	return PoolStats{
+		Limit:    int(p.limit.Load()),
+		InFlight: int(p.running.Load()),
+		Weight:   p.inFlight.Value(),
+		Blocked:  int(p.waiting.Load()),
+		Launched: p.launched.Load(),
+		Gathered: p.gathered.Load(),
+	}
+}
+
+// JobStats is a snapshot of the state of a [Job], as returned by [Job.Stats].
+type JobStats struct {
+	// InFlight is the number of tasks in the job's pools that are currently
+	// running.
+	InFlight int
+	// Ready is the number of tasks that have finished running and whose
+	// results are waiting to be gathered.
+	Ready int
+	// Blocked is the number of calls to [Scatter] currently waiting to launch
+	// a task into any of the job's pools. Unlike [PoolStats.Blocked], each is
+	// counted only once, in the pool it is launching into, even if that pool
+	// has ancestors (see [WithParent]).
+	Blocked int
+	// Launched is the total number of tasks launched into the job's pools.
+	Launched uint64
+	// Gathered is the total number of tasks whose results have been gathered.
+	Gathered uint64
+	// Closed reports whether [Job.Close] has been called.
+	Closed bool
+	// Canceled reports whether the job has been canceled, whether by
+	// [Job.Cancel], cancellation of the context passed to [NewJob], or a task
+	// failure under [WithFailFast].
+	Canceled bool
+}
+
+// Stats returns a consistent snapshot of the job's state, summing the
+// statistics of its pools (see [Pool.Stats] and [KeyedPool.Stats]). Like
+// Pool.Stats, it is thread-safe, and briefly blocks changes to the state of the
+// job and its pools while the snapshot is taken.
+func (j *Job) Stats() JobStats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	// Each pool's statistics stay locked from when they are read until all
+	// have been, so together they reflect the state when the last was locked.
+	defer lockStats(j.pools)()
+	var s JobStats
+	for _, p := range j.pools {
+		s.add(p.jobStatsLocked())
+	}
+	for _, kp := range j.keyedPools {
+		ks, unlock := kp.lockJobStats()
+		defer unlock()
+		s.add(ks)
+	}
+	s.Closed = j.closed.Load()
+	s.Canceled = j.ctx.Err() != nil
+	return s
+}
+
+// Returns the pool's contribution to the statistics of its job. Unlike
+// statsLocked, it counts only the launches blocked on the pool itself, since
+// those blocked on its descendants are counted by their own pools.
+func (p *Pool) jobStatsLocked() JobStats {
+	return JobStats{
+		InFlight: int(p.running.Load()),
+		Ready:    p.ready,
+		Blocked:  p.blocked,
+		Launched: p.launched.Load(),
+		Gathered: p.gathered.Load(),
+	}
+}
+
+func (s *JobStats) add(o JobStats) {
+	s.InFlight += o.InFlight
+	s.Ready += o.Ready
+	s.Blocked += o.Blocked
+	s.Launched += o.Launched
+	s.Gathered += o.Gathered
+}
+
+// Locks the statistics of the given pools, returning a function that unlocks
+// them. Callers must ensure that no two calls lock the same pools at once, as
+// the order in which they are locked is unspecified.
+func lockStats(pools []*Pool) func() {
+	for _, p := range pools {
+		p.statsMu.Lock()
+	}
+	return func() {
+		for _, p := range pools {
+			p.statsMu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/petenewcomb/psg-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(3)
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	release := make(chan struct{})
+	gather := func(ctx context.Context, result int, err error) error {
+		return err
+	}
+	chk.NoError(psg.Scatter(ctx, pool,
+		func(ctx context.Context) (int, error) {
+			select {
+			case <-release:
+			case <-ctx.Done():
+			}
+			return 0, nil
+		},
+		gather,
+		psg.WithWeight(2),
+	))
+	chk.NoError(psg.Scatter(ctx, pool,
+		func(ctx context.Context) (int, error) {
+			return 0, nil
+		},
+		gather,
+	))
+
+	// Block a third launch until the first task finishes.
+	blocked := make(chan error)
+	go func() {
+		blocked <- psg.Scatter(ctx, pool,
+			func(ctx context.Context) (int, error) {
+				return 0, nil
+			},
+			gather,
+			psg.WithWeight(3),
+		)
+	}()
+	// The blocked launch gathers the second task to make room, but the first
+	// is still running.
+	chk.Eventually(func() bool {
+		s := pool.Stats()
+		return s.Blocked == 1 && s.Gathered == 1
+	}, time.Second, time.Millisecond)
+	chk.Equal(psg.PoolStats{
+		Limit:    3,
+		InFlight: 1,
+		Weight:   2,
+		Blocked:  1,
+		Launched: 2,
+		Gathered: 1,
+	}, pool.Stats())
+	chk.Equal(psg.JobStats{
+		InFlight: 1,
+		Blocked:  1,
+		Launched: 2,
+		Gathered: 1,
+	}, job.Stats())
+
+	close(release)
+	chk.NoError(<-blocked)
+	chk.Eventually(func() bool {
+		return job.Stats().Ready == 1
+	}, time.Second, time.Millisecond)
+	job.Close()
+	chk.Equal(psg.JobStats{
+		Ready:    1,
+		Launched: 3,
+		Gathered: 2,
+		Closed:   true,
+	}, job.Stats())
+
+	chk.NoError(job.GatherAll(ctx))
+	job.Cancel()
+	chk.Equal(psg.JobStats{
+		Launched: 3,
+		Gathered: 3,
+		Closed:   true,
+		Canceled: true,
+	}, job.Stats())
+}
+
+func TestStatsConsistent(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(4)
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	done := make(chan error)
+	go func() {
+		for range 2000 {
+			err := psg.Scatter(ctx, pool,
+				func(ctx context.Context) (int, error) {
+					return 0, nil
+				},
+				func(ctx context.Context, result int, err error) error {
+					return err
+				},
+			)
+			if err != nil {
+				done <- err
+				return
+			}
+		}
+		job.Close()
+		done <- job.GatherAll(ctx)
+	}()
+
+	// Every launched task is running, ready, or gathered, so the counts must
+	// add up in any snapshot taken while tasks come and go.
+	for {
+		js := job.Stats()
+		chk.Equal(js.Launched, uint64(js.InFlight+js.Ready)+js.Gathered, "%+v", js)
+		ps := pool.Stats()
+		chk.LessOrEqual(ps.InFlight, ps.Weight, "%+v", ps)
+		chk.LessOrEqual(ps.Weight, ps.Limit, "%+v", ps)
+		chk.LessOrEqual(uint64(ps.InFlight)+ps.Gathered, ps.Launched, "%+v", ps)
+		select {
+		case err := <-done:
+			chk.NoError(err)
+			chk.Equal(uint64(2000), job.Stats().Gathered)
+			return
+		default:
+		}
+	}
+}
+
+func TestStatsHierarchy(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	parent := psg.NewPool(1)
+	child := psg.NewPool(1, psg.WithParent(parent))
+	job := psg.NewJob(ctx, parent, child)
+	defer job.CancelAndWait()
+
+	release := make(chan struct{})
+	task := func(ctx context.Context) (int, error) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return 0, nil
+	}
+	gather := func(ctx context.Context, result int, err error) error {
+		return err
+	}
+	chk.NoError(psg.Scatter(ctx, child, task, gather))
+
+	// Block one launch into each pool. Both count toward the parent's blocked
+	// launches, but the job must count each only once.
+	blocked := make(chan error, 2)
+	for _, pool := range []*psg.Pool{parent, child} {
+		go func() {
+			blocked <- psg.Scatter(ctx, pool, task, gather)
+		}()
+	}
+	chk.Eventually(func() bool {
+		return parent.Stats().Blocked == 2
+	}, time.Second, time.Millisecond)
+	chk.Equal(1, child.Stats().Blocked)
+	chk.Equal(psg.JobStats{
+		InFlight: 1,
+		Blocked:  2,
+		Launched: 1,
+	}, job.Stats())
+
+	close(release)
+	chk.NoError(<-blocked)
+	chk.NoError(<-blocked)
+	job.Close()
+	chk.NoError(job.GatherAll(ctx))
+	chk.Equal(psg.JobStats{
+		Launched: 3,
+		Gathered: 3,
+		Closed:   true,
+	}, job.Stats())
+}
@@ -0,0 +1,69 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTaskTimeout is the cause attached to the context of a task whose
+// per-task timeout or deadline (see [WithTimeout] and [WithDeadline]) has
+// expired. If such a task returns a non-nil error, the error passed to its
+// [GatherFunc] wraps both ErrTaskTimeout and the task's error, so that
+// errors.Is(err, ErrTaskTimeout) distinguishes a per-task timeout from
+// cancellation or expiration of the job's context.
+var ErrTaskTimeout = errors.New("task timed out")
+
+// WithTimeout returns a [ScatterOption] that limits the time the task may run.
+// The timeout is measured from when the [TaskFunc] starts executing, not from
+// the call to [Scatter], so that time spent waiting for a slot in the pool does
+// not count against it. When the timeout expires, the context passed to the
+// [TaskFunc] is canceled with [ErrTaskTimeout] as its cause.
+//
+// A non-positive timeout means no timeout. If combined with [WithDeadline],
+// whichever expires first applies.
+func WithTimeout(timeout time.Duration) ScatterOption {
+	return func(cfg *scatterConfig) {
+		cfg.timeout = timeout
+	}
+}
+
+// WithDeadline returns a [ScatterOption] that limits the task to running until
+// the given time. When the deadline passes, the context passed to the
+// [TaskFunc] is canceled with [ErrTaskTimeout] as its cause.
+//
+// A zero deadline means no deadline. If combined with [WithTimeout], whichever
+// expires first applies.
+func WithDeadline(deadline time.Time) ScatterOption {
+	return func(cfg *scatterConfig) {
+		cfg.deadline = deadline
+	}
+}
+
+// Derives the context to pass to a task function, applying the configured
+// timeout and deadline if any.
+func (cfg *scatterConfig) taskContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline := cfg.deadline
+	if cfg.timeout > 0 {
+		if d := time.Now().Add(cfg.timeout); deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+		}
+	}
+	if deadline.IsZero() {
+		return ctx, func() {}
+	}
+	return context.WithDeadlineCause(ctx, deadline, ErrTaskTimeout)
+}
+
+// Marks an error returned by a task function as a timeout if the task's own
+// deadline expired.
+func wrapTaskTimeout(ctx context.Context, err error) error {
+	if err == nil || !errors.Is(context.Cause(ctx), ErrTaskTimeout) || errors.Is(err, ErrTaskTimeout) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrTaskTimeout, err)
+}
@@ -0,0 +1,105 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/petenewcomb/psg-go"
+	"github.com/stretchr/testify/require"
+)
+
+func waitForCancel(ctx context.Context) (int, error) {
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+func TestScatterWithTimeout(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(2)
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	var timedOutErr, fastErr error
+	chk.NoError(psg.Scatter(ctx, pool, waitForCancel,
+		func(ctx context.Context, result int, err error) error {
+			timedOutErr = err
+			return nil
+		},
+		psg.WithTimeout(time.Millisecond),
+	))
+	chk.NoError(psg.Scatter(ctx, pool,
+		func(ctx context.Context) (int, error) {
+			return 1, nil
+		},
+		func(ctx context.Context, result int, err error) error {
+			chk.Equal(1, result)
+			fastErr = err
+			return nil
+		},
+		psg.WithTimeout(time.Hour),
+	))
+	chk.NoError(job.CloseAndGatherAll(ctx))
+
+	chk.ErrorIs(timedOutErr, psg.ErrTaskTimeout)
+	chk.ErrorIs(timedOutErr, context.DeadlineExceeded)
+	chk.NoError(fastErr)
+}
+
+func TestScatterWithDeadline(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	pool := psg.NewPool(1)
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	var gatheredErr error
+	chk.NoError(psg.Scatter(ctx, pool, waitForCancel,
+		func(ctx context.Context, result int, err error) error {
+			gatheredErr = err
+			return nil
+		},
+		psg.WithDeadline(time.Now().Add(time.Millisecond)),
+		psg.WithTimeout(time.Hour),
+	))
+	chk.NoError(job.CloseAndGatherAll(ctx))
+	chk.ErrorIs(gatheredErr, psg.ErrTaskTimeout)
+}
+
+func TestJobDeadlineIsNotTaskTimeout(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	// Leave the task plenty of time to start before the job's deadline, since
+	// the job drops tasks that have not started by then.
+	jobCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	pool := psg.NewPool(1)
+	job := psg.NewJob(jobCtx, pool)
+	defer job.CancelAndWait()
+
+	taskErr := make(chan error, 1)
+	chk.NoError(psg.Scatter(ctx, pool,
+		func(ctx context.Context) (int, error) {
+			_, err := waitForCancel(ctx)
+			taskErr <- err
+			return 0, err
+		},
+		func(ctx context.Context, result int, err error) error {
+			return err
+		},
+		psg.WithTimeout(time.Hour),
+	))
+	var err error
+	select {
+	case err = <-taskErr:
+	case <-time.After(10 * time.Second):
+		chk.FailNow("task did not observe the job's deadline")
+	}
+	chk.ErrorIs(err, context.DeadlineExceeded)
+	chk.NotErrorIs(err, psg.ErrTaskTimeout)
+	chk.ErrorIs(job.CloseAndGatherAll(ctx), context.DeadlineExceeded)
+}
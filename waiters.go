@@ -0,0 +1,67 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg
+
+import "sync/atomic"
+
+// Tracks the priorities of the blocked launches being held up by a pool, so
+// that lower-priority launches needing capacity in the pool can defer to
+// higher-priority ones. The pool's statsMu must be held while calling add or
+// remove.
+type waiters struct {
+	count atomic.Int64
+	// The highest priority of any waiting launch, valid only while count is
+	// non-zero.
+	max        atomic.Int64
+	byPriority map[int]int
+}
+
+func (w *waiters) add(priority int) {
+	if w.byPriority == nil {
+		w.byPriority = make(map[int]int)
+	}
+	w.byPriority[priority]++
+	if w.count.Load() == 0 || int64(priority) > w.max.Load() {
+		w.max.Store(int64(priority))
+	}
+	w.count.Add(1)
+}
+
+func (w *waiters) remove(priority int) {
+	if n := w.byPriority[priority]; n > 1 {
+		w.byPriority[priority] = n - 1
+	} else {
+		delete(w.byPriority, priority)
+		if int64(priority) == w.max.Load() {
+			w.max.Store(int64(maxKey(w.byPriority)))
+		}
+	}
+	w.count.Add(-1)
+}
+
+// Returns the greatest key in the given map, or zero if it is empty.
+func maxKey(m map[int]int) int {
+	first := true
+	var max int
+	for k := range m {
+		if first || k > max {
+			max = k
+			first = false
+		}
+	}
+	return max
+}
+
+// Reports whether any launch with a priority greater than the given one is
+// waiting. Unlike add and remove, it reads only atomics and so may be called
+// without holding the pool's statsMu. The result may then be stale, which
+// callers tolerate: a launch that defers on a stale result re-checks when next
+// woken, and one that proceeds on one merely launches ahead of a waiter that
+// arrived concurrently.
+func (w *waiters) anyAbove(priority int) bool {
+	if w.count.Load() == 0 {
+		return false
+	}
+	return w.max.Load() > int64(priority)
+}
@@ -0,0 +1,145 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+)
+
+// WithWorkers returns a [PoolOption] that makes the pool run its tasks on
+// long-lived worker goroutines instead of starting a new goroutine for each
+// task. A worker whose task has been gathered (or buffered, see
+// [WithResultBuffer]) is handed the next task launched into the pool, which
+// avoids the cost of creating a goroutine and growing its stack for each task.
+// This can substantially reduce the overhead of pools that run many short
+// tasks, at the cost of keeping idle workers around.
+//
+// Workers are started on demand, so a pool never has more workers than it has
+// tasks that are running or waiting to be gathered, plus those kept idle. Up
+// to the pool's limit (or [runtime.GOMAXPROCS], if the pool is unlimited) of
+// them are kept idle between tasks, and all of them exit once the pool's job
+// has finished or been canceled. The semantics of [Scatter], [TryScatter], and
+// gathering are unaffected.
+func WithWorkers() PoolOption {
+	return func(p *Pool) {
+		p.useWorkers = true
+	}
+}
+
+// The workers of a pool in a particular job.
+type workers struct {
+	pool *Pool
+	job  *Job
+	// Hands tasks to workers.
+	work chan func()
+	// The number of workers that are idle or whose tasks have been gathered
+	// or buffered, and which will therefore soon be waiting to receive from
+	// work without anything further being gathered.
+	free atomic.Int64
+}
+
+// Returns the pool's workers in the given job, creating them if necessary.
+func (p *Pool) workersFor(j *Job) *workers {
+	w := p.workers.Load()
+	for w == nil || w.job != j {
+		nw := &workers{
+			pool: p,
+			job:  j,
+			work: make(chan func()),
+		}
+		if p.workers.CompareAndSwap(w, nw) {
+			return nw
+		}
+		w = p.workers.Load()
+	}
+	return w
+}
+
+// Runs the given function on a free worker, or on a new worker if none are
+// free.
+func (w *workers) run(fn func()) {
+	j := w.job
+	if w.claim() {
+		select {
+		case w.work <- fn:
+			return
+		case <-j.ctx.Done():
+			// The claimed worker may have exited instead.
+		}
+	}
+	j.wg.Add(1)
+	go w.loop(fn)
+}
+
+// Claims a free worker, if there is one, to receive the next function sent
+// on w.work.
+func (w *workers) claim() bool {
+	for {
+		free := w.free.Load()
+		if free <= 0 {
+			return false
+		}
+		if w.free.CompareAndSwap(free, free-1) {
+			return true
+		}
+	}
+}
+
+// Records that the given task, run on one of the workers, has been gathered
+// or buffered (see WithResultBuffer), freeing its worker. Only the first call
+// for each task has any effect.
+func (w *workers) taskGathered(t *launchedTask) {
+	if t.freed.CompareAndSwap(false, true) {
+		w.free.Add(1)
+	}
+}
+
+// The top-level function of a worker goroutine.
+func (w *workers) loop(fn func()) {
+	j := w.job
+	defer j.wg.Done()
+	for {
+		fn()
+		if j.ctx.Err() != nil {
+			return
+		}
+		// Exit instead of idling if there are enough free workers already.
+		// Claiming a free worker, even if it is some other one, ensures that
+		// no launch is left waiting for this one.
+		if w.free.Load() > int64(w.maxIdle()) && w.claim() {
+			return
+		}
+		select {
+		case fn = <-w.work:
+		case <-j.ctx.Done():
+			return
+		case <-j.done:
+			return
+		}
+	}
+}
+
+// Returns the number of free workers to keep.
+func (w *workers) maxIdle() int {
+	if limit := w.pool.Limit(); limit >= 0 {
+		return limit
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// Wraps the gather function of the given task, run on one of the workers, to
+// free the worker once the gather has been taken. The worker will also free
+// itself once it sees that the gather was taken, but doing so here ensures that
+// the worker is free by the time the gatherer might launch another task.
+func (w *workers) wrapGather(
+	t *launchedTask,
+	gather boundGatherFunc,
+) boundGatherFunc {
+	return func(ctx context.Context) error {
+		w.taskGathered(t)
+		return gather(ctx)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright (c) Peter Newcomb. All rights reserved.
+// Licensed under the MIT License.
+
+package psg_test
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/petenewcomb/psg-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolWorkers(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	goroutines := runtime.NumGoroutine()
+
+	pool := psg.NewPool(4, psg.WithWorkers())
+	job := psg.NewJob(ctx, pool)
+	defer job.CancelAndWait()
+
+	const n = 1000
+	sum := 0
+	for i := range n {
+		chk.NoError(psg.Scatter(ctx, pool,
+			func(ctx context.Context) (int, error) {
+				return i, nil
+			},
+			func(ctx context.Context, result int, err error) error {
+				sum += result
+				return err
+			},
+		))
+	}
+	chk.NoError(job.CloseAndGatherAll(ctx))
+	chk.Equal(n*(n-1)/2, sum)
+
+	// The workers exit once the job is done.
+	job.CancelAndWait()
+	chk.LessOrEqual(runtime.NumGoroutine(), goroutines)
+}
+
+func TestPoolWorkersResultBuffer(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	goroutines := runtime.NumGoroutine()
+
+	// Workers whose results are buffered are reused before their results
+	// are gathered.
+	pool := psg.NewPool(4, psg.WithWorkers())
+	job := psg.NewJob(ctx, pool, psg.WithResultBuffer(8))
+	const n = 1000
+	sum := 0
+	for i := range n {
+		chk.NoError(psg.Scatter(ctx, pool,
+			func(ctx context.Context) (int, error) {
+				return i, nil
+			},
+			func(ctx context.Context, result int, err error) error {
+				sum += result
+				return err
+			},
+		))
+	}
+	chk.NoError(job.CloseAndGatherAll(ctx))
+	chk.Equal(n*(n-1)/2, sum)
+	job.CancelAndWait()
+	chk.LessOrEqual(runtime.NumGoroutine(), goroutines)
+}
+
+func TestPoolWorkersCancel(t *testing.T) {
+	chk := require.New(t)
+	ctx := context.Background()
+	goroutines := runtime.NumGoroutine()
+
+	pool := psg.NewPool(-1, psg.WithWorkers())
+	job := psg.NewJob(ctx, pool)
+	for range 10 {
+		chk.NoError(psg.Scatter(ctx, pool,
+			func(ctx context.Context) (int, error) {
+				<-ctx.Done()
+				return 0, ctx.Err()
+			},
+			func(ctx context.Context, result int, err error) error {
+				return err
+			},
+		))
+	}
+
+	// CancelAndWait waits for the workers, busy or idle, to exit.
+	job.CancelAndWait()
+	chk.LessOrEqual(runtime.NumGoroutine(), goroutines)
+}
+
+func BenchmarkScatter(b *testing.B) {
+	for _, bm := range []struct {
+		name string
+		opts []psg.PoolOption
+	}{
+		{"goroutines", nil},
+		{"workers", []psg.PoolOption{psg.WithWorkers()}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			ctx := context.Background()
+			pool := psg.NewPool(runtime.GOMAXPROCS(0), bm.opts...)
+			job := psg.NewJob(ctx, pool)
+			defer job.CancelAndWait()
+
+			task := func(ctx context.Context) (int, error) {
+				// A short CPU-bound task, like hashing a small block.
+				h := 0
+				for i := range 100 {
+					h = h*31 + i
+				}
+				return h, nil
+			}
+			gather := func(ctx context.Context, result int, err error) error {
+				return err
+			}
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := psg.Scatter(ctx, pool, task, gather); err != nil {
+					b.Fatal(err)
+				}
+			}
+			if err := job.CloseAndGatherAll(ctx); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}